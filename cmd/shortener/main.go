@@ -1,11 +1,63 @@
 package main
 
-import "shortener/internal/app"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"shortener/internal/app"
+	"shortener/internal/config"
+)
 
 func main() {
-	a := app.New()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stdout, "Usage of shortener [flags] [migrate up|down|status | admin grant|revoke <email> | config print]:")
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "\nUsage of shortener [flags] [migrate up|down|status | admin grant|revoke <email> | config print]:")
+		config.Usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	var cmd string
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "":
+		a := app.New(cfg)
 
-	if err := a.Run(); err != nil {
-		panic(err)
+		if err := a.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := app.Migrate(cfg, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "admin":
+		if err := app.Admin(cfg, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "config":
+		if len(args) != 2 || args[1] != "print" {
+			fmt.Fprintln(os.Stderr, "usage: shortener config print")
+			os.Exit(2)
+		}
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shortener/internal/config"
+	"shortener/internal/model"
+	"shortener/internal/service"
+	"shortener/internal/shared/logger"
+)
+
+const adminUsage = "usage: shortener [flags] admin grant|revoke <email>"
+
+// Admin runs the admin subcommand, which grants or revokes the admin role
+// of a registered account in the configured storage.
+func Admin(cfg *config.Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New(adminUsage)
+	}
+
+	var role string
+	switch args[0] {
+	case "grant":
+		role = model.RoleAdmin
+	case "revoke":
+	default:
+		return errors.New(adminUsage)
+	}
+
+	log := logger.New(cfg.App.LogLevel)
+
+	ctx := context.Background()
+	repo, err := openStorage(ctx, cfg, log)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	u, err := service.NewAccountService(repo, repo).SetRole(ctx, args[1], role)
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		fmt.Printf("revoked admin role of %s\n", u.Email)
+	} else {
+		fmt.Printf("granted admin role to %s\n", u.Email)
+	}
+	return nil
+}
@@ -2,20 +2,38 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"shortener/internal/config"
+	grpchandler "shortener/internal/handler/grpc"
+	"shortener/internal/handler/grpc/pb"
 	handler "shortener/internal/handler/http"
+	"shortener/internal/model"
 	frepo "shortener/internal/repo/file"
 	mrepo "shortener/internal/repo/memory"
 	"shortener/internal/repo/pg"
+	rrepo "shortener/internal/repo/redis"
+	srepo "shortener/internal/repo/sqlite"
 	"shortener/internal/service"
 	"shortener/internal/shared/compress/gzip"
 	"shortener/internal/shared/database/postgres"
+	rdb "shortener/internal/shared/database/redis"
+	"shortener/internal/shared/database/sqlite"
 	"shortener/internal/shared/logger"
+	"shortener/internal/shared/metrics"
+	"shortener/internal/shared/tracing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type urlHandler interface {
@@ -29,106 +47,491 @@ type urlHandler interface {
 	URLByID(w http.ResponseWriter, r *http.Request)
 }
 
+type statsHandler interface {
+	LinkStats(w http.ResponseWriter, r *http.Request)
+	ServiceStats(w http.ResponseWriter, r *http.Request)
+}
+
+type authHandler interface {
+	Token(w http.ResponseWriter, r *http.Request)
+	Register(w http.ResponseWriter, r *http.Request)
+	Login(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+}
+
+type apiKeyHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
+
+type adminHandler interface {
+	Lookup(w http.ResponseWriter, r *http.Request)
+	UserURLs(w http.ResponseWriter, r *http.Request)
+	Disable(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
+	Transfer(w http.ResponseWriter, r *http.Request)
+	AuditLog(w http.ResponseWriter, r *http.Request)
+}
+
 type Registrator interface {
 	CheckInMiddleware(http.Handler) http.Handler
+	RequireUser(http.Handler) http.Handler
+	RequireScope(string) func(http.Handler) http.Handler
+	RequireRole(string) func(http.Handler) http.Handler
+	RequireSession(http.Handler) http.Handler
+}
+
+// storage is what every repository backend provides.
+type storage interface {
+	service.URLRepository
+	service.ClickRepository
+	service.ClickStatsRepository
+	service.UserRepository
+	service.APIKeyRepository
+	service.AdminRepository
+	service.SequenceRepository
+	Close() error
+}
+
+// queue is a background writer that drains once the app context is
+// cancelled.
+type queue interface {
+	Wait(context.Context) error
 }
 
 type App struct {
-	log    *logger.Logger
-	srv    *http.Server
-	cancel context.CancelFunc
+	log             *logger.Logger
+	srv             *http.Server
+	redirectSrv     *http.Server
+	grpcSrv         *grpc.Server
+	grpcAddr        string
+	repo            storage
+	deletes         queue
+	clicks          queue
+	shutdownTimeout time.Duration
+	cancel          context.CancelFunc
+	stopTracing     func(context.Context) error
 }
 
-func New() *App {
+func New(cfg *config.Config) *App {
 	a := &App{}
-	a.initDeps()
+	a.initDeps(cfg)
 
 	return a
 }
 
+// Run serves until SIGINT or SIGTERM arrives or a server fails, then
+// shuts down gracefully.
 func (a *App) Run() error {
 	defer a.log.Sync()
-	defer a.cancel()
-
-	a.log.Info(
-		"Server started",
-		logger.String("addr", a.srv.Addr),
-	)
-	err := a.srv.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 3)
+	if a.grpcSrv != nil {
+		lis, err := net.Listen("tcp", a.grpcAddr)
+		if err != nil {
+			a.log.Error("grpc listen", logger.Error(err))
+			return errors.Join(err, a.shutdown())
+		}
+
+		go func() {
+			a.log.Info("gRPC server started", logger.String("addr", a.grpcAddr))
+			if err := a.grpcSrv.Serve(lis); err != nil {
+				errCh <- fmt.Errorf("grpc server: %w", err)
+			}
+		}()
+	}
+
+	if a.redirectSrv != nil {
+		go func() {
+			a.log.Info("HTTPS redirect started", logger.String("addr", a.redirectSrv.Addr))
+			if err := a.redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("redirect server: %w", err)
+			}
+		}()
+	}
+
+	go func() {
+		a.log.Info(
+			"Server started",
+			logger.String("addr", a.srv.Addr),
+			logger.Bool("tls", a.srv.TLSConfig != nil),
+		)
+		var err error
+		if a.srv.TLSConfig != nil {
+			err = a.srv.ListenAndServeTLS("", "")
+		} else {
+			err = a.srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("http server: %w", err)
+		}
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		a.log.Info("shutdown: signal received")
+	case err = <-errCh:
 		a.log.Error("server error", logger.Error(err))
-		return err
 	}
-	return nil
+	stop()
+
+	return errors.Join(err, a.shutdown())
+}
+
+// shutdown stops the servers, drains the background queues, stops the
+// background jobs, closes storage and flushes traces, in that order,
+// within shutdownTimeout.
+func (a *App) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+
+	a.log.Info("shutdown: stopping servers")
+	if err := a.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+	}
+	if a.redirectSrv != nil {
+		if err := a.redirectSrv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("redirect shutdown: %w", err))
+		}
+	}
+	if a.grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			a.grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			a.grpcSrv.Stop()
+			errs = append(errs, fmt.Errorf("grpc shutdown: %w", ctx.Err()))
+		}
+	}
+
+	a.log.Info("shutdown: draining queues and stopping background jobs")
+	a.cancel()
+	// The delete queue and the expiry reaper share a wait, and the file
+	// backend stops compacting in Close, so nothing is writing to
+	// storage once it is closed.
+	if err := a.deletes.Wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain delete queue and stop reaper: %w", err))
+	}
+	if err := a.clicks.Wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain click queue: %w", err))
+	}
+
+	a.log.Info("shutdown: closing storage")
+	if err := a.repo.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage: %w", err))
+	}
+
+	a.log.Info("shutdown: flushing traces")
+	if err := a.stopTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flush traces: %w", err))
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		a.log.Error("shutdown: finished with errors", logger.Error(err))
+	} else {
+		a.log.Info("shutdown: complete")
+	}
+	return err
 }
 
-func (a *App) initDeps() {
+func (a *App) initDeps(cfg *config.Config) {
 	ctx, cancel := context.WithCancel(context.Background())
 	a.cancel = cancel
 
-	cfg := config.MustLoad()
 	log := logger.New(cfg.App.LogLevel)
 
-	var repo service.URLRepository
-	var err error
+	stopTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.Endpoint)
+	if err != nil {
+		logger.Fatal("tracing setup", logger.Error(err))
+	}
+	if cfg.Tracing.Exporter != "" {
+		log.Info("Tracing enabled", logger.String("exporter", cfg.Tracing.Exporter))
+	}
+	a.stopTracing = stopTracing
+
+	repo, err := openStorage(ctx, cfg, log)
+	if err != nil {
+		logger.Fatal("open storage", logger.Error(err))
+	}
+	if cfg.Cache.Size > 0 {
+		if sharedStorage(cfg) {
+			log.Warn("Link cache in front of shared storage: other replicas' changes show up after the cache TTL",
+				logger.Duration("ttl", cfg.Cache.TTL))
+		}
+		cached := newCachedStorage(repo, cfg.Cache)
+		if err := metrics.RegisterCache(cached.cache.Counts); err != nil {
+			log.Warn("register cache metrics", logger.Error(err))
+		}
+		repo = cached
+	}
+	repo = newTracedStorage(repo)
+
+	codes, err := service.NewCodeGenerator(cfg.Codes.Strategy, cfg.Codes.Alphabet, cfg.Codes.Length, repo)
+	if err != nil {
+		logger.Fatal("new code generator", logger.Error(err))
+	}
+
+	aliases := service.AliasPolicy{
+		Charset:   cfg.Aliases.Charset,
+		MinLength: cfg.Aliases.MinLength,
+		MaxLength: cfg.Aliases.MaxLength,
+		Reserved:  reservedPaths,
+	}
+
+	urlSvc := service.NewURLService(ctx, cfg.App.BaseAddr, repo, codes, aliases, cfg.DB.ReapInterval)
+	keys, err := signingKeys(cfg.Auth)
+	if err != nil {
+		logger.Fatal("signing keys", logger.Error(err))
+	}
+	apiKeySvc := service.NewAPIKeyService(log, repo)
+	authSvc, err := service.NewAuthService(log, keys, apiKeySvc, repo, cfg.Auth.TokenExpire, cfg.Auth.TokenRefresh, cfg.TLS.Enabled)
+	if err != nil {
+		logger.Fatal("new auth service", logger.Error(err))
+	}
+	salt, err := clickIPSalt(cfg.Analytics)
+	if err != nil {
+		logger.Fatal("click ip salt", logger.Error(err))
+	}
+	clickSvc := service.NewClickService(ctx, repo, salt, cfg.Analytics.BufferSize)
+	statsSvc := service.NewStatsService(repo, repo, repo)
+	h := handler.NewURLHandler(log, urlSvc, authSvc, clickSvc)
+	sh := handler.NewStatsHandler(log, statsSvc, authSvc)
+
+	trusted, err := parseSubnet(cfg.App.TrustedSubnet)
+	if err != nil {
+		logger.Fatal("trusted subnet", logger.Error(err))
+	}
+	metricsNet, err := parseSubnet(cfg.App.MetricsSubnet)
+	if err != nil {
+		logger.Fatal("metrics subnet", logger.Error(err))
+	}
+	ah := handler.NewAuthHandler(log, authSvc, service.NewAccountService(repo, repo))
+	kh := handler.NewAPIKeyHandler(log, apiKeySvc, authSvc)
+	adh := handler.NewAdminHandler(log, service.NewAdminService(repo, repo), authSvc)
+	r := router(h, sh, ah, kh, adh, authSvc, trusted, metricsNet)
+
+	a.log = log
+	a.repo = repo
+	a.deletes = urlSvc
+	a.clicks = clickSvc
+	a.shutdownTimeout = cfg.App.ShutdownTimeout
+	a.srv = &http.Server{
+		Addr:    cfg.App.Addr(),
+		Handler: r,
+	}
+
+	var grpcOpts []grpc.ServerOption
+	if cfg.TLS.Enabled {
+		tc, wrapRedirect, err := tlsSetup(cfg.TLS, []string{cfg.App.Host, "localhost", "127.0.0.1"})
+		if err != nil {
+			logger.Fatal("tls setup", logger.Error(err))
+		}
+		a.srv.TLSConfig = tc
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tc)))
+
+		if cfg.TLS.RedirectAddr != "" {
+			a.redirectSrv = &http.Server{
+				Addr:    cfg.TLS.RedirectAddr,
+				Handler: wrapRedirect(redirectHTTPS(cfg.App.Port)),
+			}
+		}
+		log.Info("HTTPS enabled", logger.String("mode", cfg.TLS.Mode))
+	}
+
+	if cfg.App.GRPCAddr != "" {
+		a.grpcAddr = cfg.App.GRPCAddr
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(
+			logger.InterceptorGRPC,
+			grpchandler.Recoverer,
+			authSvc.CheckInInterceptor,
+		))
+		a.grpcSrv = grpc.NewServer(grpcOpts...)
+		pb.RegisterShortenerServiceServer(a.grpcSrv, grpchandler.NewShortenerServer(log, urlSvc, authSvc))
+	}
+}
+
+// openStorage picks the backend: SQLite or postgres when a DSN is set,
+// else Redis when a URL is set, else the file storage, else memory.
+// sharedStorage reports whether openStorage picks a backend that other
+// replicas can use too.
+func sharedStorage(cfg *config.Config) bool {
+	if sqlite.IsDSN(cfg.DB.DSN) {
+		return false
+	}
+	return cfg.DB.DSN != "" || cfg.Redis.URL != ""
+}
+
+func openStorage(ctx context.Context, cfg *config.Config, log *logger.Logger) (storage, error) {
+	if sqlite.IsDSN(cfg.DB.DSN) {
+		db, err := sqlite.NewConnect(ctx, cfg.DB.DSN)
+		if err != nil {
+			return nil, err
+		}
+
+		repo, err := srepo.NewURLRepository(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("new sqlite storage: %w", err)
+		}
+		log.Info("Using sqlite storage")
+		return repo, nil
+	}
+
 	if cfg.DB.DSN != "" {
 		db, err := postgres.NewConnect(ctx, cfg.DB.DSN)
 		if err != nil {
-			logger.Fatal("", logger.ErrorS(err.Error()))
+			return nil, err
 		}
 		log.Info("The database is connected")
+		if err := metrics.RegisterPool(db); err != nil {
+			log.Warn("register pool metrics", logger.Error(err))
+		}
 
-		repo, err = pg.NewURLRepository(ctx, db)
+		repo, err := pg.NewURLRepository(ctx, db)
 		if err != nil {
-			logger.Fatal("new postgres storage", logger.ErrorS(err.Error()))
+			return nil, fmt.Errorf("new postgres storage: %w", err)
 		}
 		log.Info("Using postgres storage")
-	} else if cfg.DB.FileStorage != "" {
-		repo, err = frepo.NewURLRepository(cfg.DB.FileStorage)
+		return repo, nil
+	}
+
+	if cfg.Redis.URL != "" {
+		client, err := rdb.NewConnect(ctx, cfg.Redis.URL)
 		if err != nil {
-			logger.Fatal("failed to create new file repository")
+			return nil, err
 		}
-		log.Info("Using file storage")
-	} else {
-		repo, err = mrepo.NewURLRepository()
+
+		repo, err := rrepo.NewURLRepository(ctx, client)
 		if err != nil {
-			logger.Fatal("failed to create new in-memory repository")
+			client.Close()
+			return nil, fmt.Errorf("new redis storage: %w", err)
 		}
-		log.Info("Using in-memory storage")
+		log.Info("Using redis storage")
+		return repo, nil
 	}
 
-	urlSvc := service.NewURLService(ctx, cfg.App.BaseAddr, repo)
-	authSvc := service.NewAuthService(log, cfg.Auth.Secret, cfg.Auth.TokenExpire)
-	h := handler.NewURLHandler(log, urlSvc, authSvc)
-	r := router(h, authSvc)
+	if cfg.DB.FileStorage != "" {
+		repo, err := frepo.NewURLRepository(ctx, cfg.DB.FileStorage)
+		if err != nil {
+			return nil, fmt.Errorf("new file storage: %w", err)
+		}
+		log.Info("Using file storage")
+		return repo, nil
+	}
 
-	a.log = log
-	a.srv = &http.Server{
-		Addr:    cfg.App.Addr(),
-		Handler: r,
+	repo, err := mrepo.NewURLRepository()
+	if err != nil {
+		return nil, fmt.Errorf("new in-memory storage: %w", err)
 	}
+	log.Info("Using in-memory storage")
+	return repo, nil
 }
 
-func router(h urlHandler, reg Registrator) http.Handler {
+// reservedPaths are the first path segments taken by router, which
+// custom aliases must not shadow.
+var reservedPaths = []string{"api", "ping", "metrics"}
+
+func router(
+	h urlHandler,
+	sh statsHandler,
+	ah authHandler,
+	kh apiKeyHandler,
+	adh adminHandler,
+	reg Registrator,
+	trusted *net.IPNet,
+	metricsNet *net.IPNet,
+) http.Handler {
 	r := chi.NewRouter()
+	scope := reg.RequireScope
 
-	r.Use(logger.MiddlewareHTTP)
+	r.Use(tracing.MiddlewareHTTP)
+	r.Use(logger.ObserveHTTP(metrics.ObserveHTTP, tracing.ObserveHTTP))
 	r.Use(middleware.Recoverer)
-	r.Use(reg.CheckInMiddleware)
 
-	r.With(middleware.AllowContentType("text/plain", "text/html", "application/x-gzip"), gzip.Middleware).
-		Post("/", h.ShortenURLText)
-	r.With(middleware.AllowContentType("application/json"), gzip.Middleware).
-		Post("/api/shorten", h.ShortenURLJSON)
-	r.With(middleware.AllowContentType("application/json"), gzip.Middleware).
-		Post("/api/shorten/batch", h.ShortenBatchJSON)
+	// Endpoints that may create a user: a bad token gets a new identity.
+	r.Group(func(r chi.Router) {
+		r.Use(reg.CheckInMiddleware)
 
-	r.Get("/{short}", h.RedirectURL)
-	r.Get("/{id:[0-9]+}", h.URLByID)
-	r.Get("/api/user/urls", h.AllUserURLs)
-	r.Get("/ping", h.PingDB)
+		r.With(scope(model.ScopeShorten), middleware.AllowContentType("text/plain", "text/html", "application/x-gzip"), gzip.Middleware).
+			Post("/", h.ShortenURLText)
+		r.With(scope(model.ScopeShorten), middleware.AllowContentType("application/json"), gzip.Middleware).
+			Post("/api/shorten", h.ShortenURLJSON)
+		r.With(scope(model.ScopeShorten), middleware.AllowContentType("application/json"), gzip.Middleware).
+			Post("/api/shorten/batch", h.ShortenBatchJSON)
 
-	r.Delete("/api/user/urls", h.DeleteURLs)
+		r.Get("/{short}", h.RedirectURL)
+		r.Get("/{id:[0-9]+}", h.URLByID)
+		r.Get("/ping", h.PingDB)
+		r.With(handler.TrustedSubnet(trusted)).Get("/api/internal/stats", sh.ServiceStats)
+
+		r.With(reg.RequireSession, middleware.AllowContentType("application/json")).
+			Post("/api/auth/register", ah.Register)
+		r.With(reg.RequireSession, middleware.AllowContentType("application/json")).
+			Post("/api/auth/login", ah.Login)
+	})
+
+	r.Post("/api/auth/logout", ah.Logout)
+	r.Method(http.MethodGet, "/metrics", metricsHandler(metricsNet))
+
+	// Endpoints about an existing user: a bad token is a 401.
+	r.Group(func(r chi.Router) {
+		r.Use(reg.RequireUser)
+
+		r.With(scope(model.ScopeRead)).Get("/api/user/urls", h.AllUserURLs)
+		r.With(scope(model.ScopeStats)).Get("/api/user/urls/{short}/stats", sh.LinkStats)
+		r.With(scope(model.ScopeDelete)).Delete("/api/user/urls", h.DeleteURLs)
+
+		// A key must not mint credentials with more rights than its own.
+		r.Group(func(r chi.Router) {
+			r.Use(reg.RequireSession)
+
+			r.Post("/api/auth/token", ah.Token)
+			r.With(middleware.AllowContentType("application/json")).Post("/api/user/keys", kh.Create)
+			r.Get("/api/user/keys", kh.List)
+			r.Delete("/api/user/keys/{id}", kh.Revoke)
+		})
+	})
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(reg.RequireUser, reg.RequireSession, reg.RequireRole(model.RoleAdmin))
+
+		r.Get("/urls", adh.Lookup)
+		r.Post("/urls/{short}/disable", adh.Disable)
+		r.Post("/urls/{short}/restore", adh.Restore)
+		r.With(middleware.AllowContentType("application/json")).Post("/urls/{short}/transfer", adh.Transfer)
+		r.Get("/users/{id}/urls", adh.UserURLs)
+		r.Get("/audit", adh.AuditLog)
+	})
 
 	return r
 }
+
+// parseSubnet parses a CIDR setting, which may be empty.
+func parseSubnet(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+	_, subnet, err := net.ParseCIDR(cidr)
+	return subnet, err
+}
+
+// metricsHandler serves the metrics to everyone, or only to subnet when
+// one is set.
+func metricsHandler(subnet *net.IPNet) http.Handler {
+	if subnet == nil {
+		return metrics.Handler()
+	}
+	return handler.TrustedSubnet(subnet)(metrics.Handler())
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+
+	"shortener/internal/config"
+	"shortener/internal/repo/cache"
+	"shortener/internal/service"
+)
+
+type urlCache interface {
+	service.URLRepository
+	Invalidate(short string)
+	Counts() (hits, misses uint64)
+}
+
+// cachedStorage reads links through a cache and leaves everything else
+// to the storage. The admin writes that change a link evict it.
+type cachedStorage struct {
+	urlStorage
+	cache urlCache
+}
+
+func newCachedStorage(s storage, cfg config.Cache) *cachedStorage {
+	c := cache.NewURLRepository(s, cfg.Size, cfg.TTL, cfg.NegativeTTL)
+	return &cachedStorage{urlStorage: urlStorage{storage: s, urls: c}, cache: c}
+}
+
+func (s *cachedStorage) SetDeleted(ctx context.Context, short string, deleted bool) error {
+	defer s.cache.Invalidate(short)
+	return s.storage.SetDeleted(ctx, short, deleted)
+}
+
+func (s *cachedStorage) ReassignURL(ctx context.Context, short, to string) error {
+	defer s.cache.Invalidate(short)
+	return s.storage.ReassignURL(ctx, short, to)
+}
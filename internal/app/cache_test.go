@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/config"
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedStorageAdminWrites(t *testing.T) {
+	ctx := context.Background()
+	mem, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	s := newCachedStorage(mem, config.Cache{Size: 10, TTL: time.Minute})
+
+	_, err = s.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, "abc")
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetDeleted(ctx, "abc", true))
+	_, err = s.Get(ctx, "abc")
+	assert.ErrorIs(t, err, model.ErrDeleted)
+
+	require.NoError(t, s.SetDeleted(ctx, "abc", false))
+	require.NoError(t, s.ReassignURL(ctx, "abc", "u2"))
+	u, err := s.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "u2", u.UserID)
+}
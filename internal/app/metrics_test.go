@@ -0,0 +1,39 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shortener/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	scrape := func(h http.Handler, realIP string) int {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if realIP != "" {
+			r.Header.Set("X-Real-IP", realIP)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// The default config leaves /metrics open, whatever TRUSTED_SUBNET
+	// says about the internal endpoints.
+	cfg, _, err := config.Load(nil)
+	require.NoError(t, err)
+	require.Empty(t, cfg.App.TrustedSubnet)
+	subnet, err := parseSubnet(cfg.App.MetricsSubnet)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, scrape(metricsHandler(subnet), ""))
+
+	subnet, err = parseSubnet("10.0.0.0/8")
+	require.NoError(t, err)
+	h := metricsHandler(subnet)
+	assert.Equal(t, http.StatusOK, scrape(h, "10.1.2.3"))
+	assert.Equal(t, http.StatusForbidden, scrape(h, "192.0.2.1"))
+}
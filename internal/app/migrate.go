@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"shortener/internal/config"
+	"shortener/internal/repo/pg"
+	srepo "shortener/internal/repo/sqlite"
+	"shortener/internal/shared/database/migrate"
+	"shortener/internal/shared/database/postgres"
+	"shortener/internal/shared/database/sqlite"
+	"shortener/internal/shared/logger"
+)
+
+const migrateUsage = "usage: shortener [flags] migrate up|down|status"
+
+// Migrate runs the migrate subcommand against the configured database.
+func Migrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.DB.DSN == "" {
+		return errors.New("migrate: database DSN is not set")
+	}
+
+	logger.New(cfg.App.LogLevel)
+
+	ctx := context.Background()
+	m, closeDB, err := openMigrator(ctx, cfg.DB.DSN)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		st, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range st {
+			at := "pending"
+			if s.Applied {
+				at = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, at)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+// openMigrator connects to the database dsn points to and returns its
+// migrator along with a function that closes the connection.
+func openMigrator(ctx context.Context, dsn string) (*migrate.Migrator, func(), error) {
+	if sqlite.IsDSN(dsn) {
+		db, err := sqlite.NewConnect(ctx, dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		m, err := srepo.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return m, func() { db.Close() }, nil
+	}
+
+	db, err := postgres.NewConnect(ctx, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := pg.NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return m, func() {
+		m.Close()
+		db.Close()
+	}, nil
+}
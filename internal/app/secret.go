@@ -0,0 +1,104 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shortener/internal/config"
+	"shortener/internal/model"
+)
+
+// secretSize is the length in bytes of generated secrets, matching the
+// HS256 output size.
+const secretSize = 32
+
+// signingKeys lists the keys for auth tokens, the signing key first.
+// Without configured keys it falls back to the secret file, generating
+// one on first start, so tokens are never signed with an empty key.
+func signingKeys(cfg config.Auth) ([]model.SigningKey, error) {
+	keys := append([]model.SigningKey(nil), cfg.Keys...)
+	if len(cfg.Secret) > 0 {
+		keys = append(keys, model.SigningKey{Secret: cfg.Secret})
+	}
+	if len(keys) > 0 {
+		return keys, nil
+	}
+
+	if cfg.SecretFile == "" {
+		return nil, errors.New("app.signingKeys error: no secret configured")
+	}
+	secret, err := loadOrCreateSecret(cfg.SecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("app.signingKeys error: %w", err)
+	}
+
+	return []model.SigningKey{{Secret: secret}}, nil
+}
+
+// clickIPSalt returns the key for hashing client IPs of clicks, falling
+// back to the salt file like signingKeys does: an empty key would let
+// anyone reverse the hashes by trying every address.
+func clickIPSalt(cfg config.Analytics) ([]byte, error) {
+	if cfg.IPSalt != "" {
+		return []byte(cfg.IPSalt), nil
+	}
+
+	if cfg.IPSaltFile == "" {
+		return nil, errors.New("app.clickIPSalt error: no salt configured")
+	}
+	salt, err := loadOrCreateSecret(cfg.IPSaltFile)
+	if err != nil {
+		return nil, fmt.Errorf("app.clickIPSalt error: %w", err)
+	}
+
+	return salt, nil
+}
+
+func loadOrCreateSecret(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("secret file %s is not a hex encoded secret", path)
+		}
+		return secret, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read secret file: %w", err)
+	}
+
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create secret dir: %w", err)
+		}
+	}
+	// O_EXCL keeps two replicas starting at once from overwriting each
+	// other's secret; the loser reads the winner's.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return loadOrCreateSecret(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create secret file: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(secret) + "\n"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write secret file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write secret file: %w", err)
+	}
+
+	return secret, nil
+}
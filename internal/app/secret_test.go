@@ -0,0 +1,74 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shortener/internal/config"
+	"shortener/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningKeys(t *testing.T) {
+	t.Run("configured keys come first", func(t *testing.T) {
+		keys, err := signingKeys(config.Auth{
+			Secret: []byte("old"),
+			Keys:   []model.SigningKey{{ID: "k2", Secret: []byte("new")}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []model.SigningKey{
+			{ID: "k2", Secret: []byte("new")},
+			{Secret: []byte("old")},
+		}, keys)
+	})
+
+	t.Run("generated once and persisted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nested", "secret.key")
+
+		first, err := signingKeys(config.Auth{SecretFile: path})
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		assert.Len(t, first[0].Secret, secretSize)
+
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+		again, err := signingKeys(config.Auth{SecretFile: path})
+		require.NoError(t, err)
+		assert.Equal(t, first, again)
+	})
+
+	t.Run("corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secret.key")
+		require.NoError(t, os.WriteFile(path, []byte("not hex"), 0o600))
+
+		_, err := signingKeys(config.Auth{SecretFile: path})
+		assert.Error(t, err)
+	})
+
+	t.Run("nothing configured", func(t *testing.T) {
+		_, err := signingKeys(config.Auth{})
+		assert.Error(t, err)
+	})
+}
+
+func TestClickIPSalt(t *testing.T) {
+	salt, err := clickIPSalt(config.Analytics{IPSalt: "pepper", IPSaltFile: "unused"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("pepper"), salt)
+
+	path := filepath.Join(t.TempDir(), "salt.key")
+	first, err := clickIPSalt(config.Analytics{IPSaltFile: path})
+	require.NoError(t, err)
+	assert.Len(t, first, secretSize)
+	again, err := clickIPSalt(config.Analytics{IPSaltFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	_, err = clickIPSalt(config.Analytics{})
+	assert.Error(t, err)
+}
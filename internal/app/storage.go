@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/traced"
+	"shortener/internal/service"
+)
+
+// urlStorage sends link calls to urls, a decorator of the storage's own
+// URLRepository, and everything else to the storage.
+type urlStorage struct {
+	storage
+	urls service.URLRepository
+}
+
+// newTracedStorage records a span for every link call.
+func newTracedStorage(s storage) *urlStorage {
+	return &urlStorage{storage: s, urls: traced.NewURLRepository(s)}
+}
+
+func (s *urlStorage) Ping(ctx context.Context) error { return s.urls.Ping(ctx) }
+
+func (s *urlStorage) Save(ctx context.Context, u model.URLStore) (string, error) {
+	return s.urls.Save(ctx, u)
+}
+
+func (s *urlStorage) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	return s.urls.SaveAll(ctx, urls)
+}
+
+func (s *urlStorage) Get(ctx context.Context, short string) (model.URLStore, error) {
+	return s.urls.Get(ctx, short)
+}
+
+func (s *urlStorage) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	return s.urls.GetByID(ctx, uuid)
+}
+
+func (s *urlStorage) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	return s.urls.GetAllByUser(ctx, userID)
+}
+
+func (s *urlStorage) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	return s.urls.DeleteBatch(ctx, userID, urls)
+}
+
+func (s *urlStorage) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	return s.urls.DeleteExpired(ctx, before)
+}
+
+func (s *urlStorage) Stats(ctx context.Context) (model.ServiceStats, error) {
+	return s.urls.Stats(ctx)
+}
+
+func (s *urlStorage) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	return s.urls.ReassignUser(ctx, from, to)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shortener/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfSigned(t *testing.T) {
+	now := time.Now()
+	cert, err := selfSigned([]string{"localhost", "127.0.0.1", ""}, now)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, leaf.DNSNames)
+	require.Len(t, leaf.IPAddresses, 1)
+	assert.Equal(t, "127.0.0.1", leaf.IPAddresses[0].String())
+	assert.NoError(t, leaf.VerifyHostname("localhost"))
+	assert.True(t, leaf.NotAfter.After(now.AddDate(0, 11, 0)))
+}
+
+func TestTLSSetup(t *testing.T) {
+	_, _, err := tlsSetup(config.TLS{Mode: config.TLSModeStatic, CertFile: "missing.crt", KeyFile: "missing.key"}, nil)
+	assert.Error(t, err)
+
+	tc, _, err := tlsSetup(config.TLS{Mode: config.TLSModeSelfSigned}, []string{"localhost"})
+	require.NoError(t, err)
+	assert.Len(t, tc.Certificates, 1)
+
+	tc, wrap, err := tlsSetup(config.TLS{Mode: config.TLSModeAutocert, Domains: []string{"short.my"}, CacheDir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	assert.NotNil(t, tc.GetCertificate)
+	assert.NotNil(t, wrap)
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	testCases := []struct {
+		name   string
+		port   string
+		target string
+		want   string
+	}{
+		{"default port", "443", "http://short.my/abc?x=1", "https://short.my/abc?x=1"},
+		{"custom port", "8443", "http://short.my:8080/abc", "https://short.my:8443/abc"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tc.target, nil)
+
+			redirectHTTPS(tc.port).ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tc.want, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads a flat JSON or YAML object keyed like the settings,
+// e.g. {"server_address": "localhost:8080", "tls_domains": ["a", "b"]}.
+// Values are kept as strings and parsed together with the other layers.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	known := make(map[string]struct{}, len(settings))
+	for _, s := range settings {
+		known[s.key] = struct{}{}
+	}
+
+	res := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if _, ok := known[k]; !ok {
+			return nil, fmt.Errorf("config file %s: unknown setting %q", path, k)
+		}
+		s, err := scalar(v)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, k, err)
+		}
+		res[k] = s
+	}
+
+	return res, nil
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("nested objects are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Print writes the configuration as YAML that Load accepts back, with
+// secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings {
+		v := s.bind(c).String()
+		if s.redact != nil {
+			v = s.redact(v)
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: s.key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: v, Style: style(v)},
+		)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("config.Print error: %w", err)
+	}
+	return enc.Close()
+}
+
+// style quotes values YAML would otherwise read as something other than
+// a plain string, such as an empty value.
+func style(v string) yaml.Style {
+	if v == "" {
+		return yaml.DoubleQuotedStyle
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+)
+
+const defaultAliasCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+// setting is one configuration value. key names it in config files; the
+// environment variable is key in upper case.
+type setting struct {
+	key    string
+	flag   string
+	def    string
+	usage  string
+	isBool bool
+	// redact hides secrets when the configuration is printed.
+	redact func(string) string
+	bind   func(*Config) flag.Value
+}
+
+func (s setting) env() string { return strings.ToUpper(s.key) }
+
+var settings = []setting{
+	{key: "server_address", flag: "a", def: "localhost:8080", usage: "HTTP server address",
+		bind: func(c *Config) flag.Value { return &addrValue{&c.App.Host, &c.App.Port} }},
+	{key: "base_url", flag: "b", def: "localhost:8080", usage: "base short URL address",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.App.BaseAddr) }},
+	{key: "log_level", flag: "l", def: "info", usage: "log level",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.App.LogLevel) }},
+	{key: "shutdown_timeout", flag: "shutdown-timeout", def: "10s", usage: "graceful shutdown deadline",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.App.ShutdownTimeout) }},
+	{key: "grpc_address", flag: "g", def: "localhost:3200", usage: "gRPC server address, empty disables it",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.App.GRPCAddr) }},
+	{key: "trusted_subnet", flag: "t", usage: "trusted subnet CIDR for internal endpoints",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.App.TrustedSubnet) }},
+	{key: "metrics_subnet", flag: "metrics-subnet", usage: "CIDR allowed to scrape /metrics, empty allows everyone",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.App.MetricsSubnet) }},
+
+	{key: "enable_https", flag: "s", def: "false", usage: "serve HTTPS", isBool: true,
+		bind: func(c *Config) flag.Value { return (*boolValue)(&c.TLS.Enabled) }},
+	{key: "tls_mode", flag: "tls-mode", def: TLSModeStatic, usage: "certificate source: static, self-signed or autocert",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.TLS.Mode) }},
+	{key: "tls_cert_file", flag: "tls-cert", usage: "TLS certificate file",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.TLS.CertFile) }},
+	{key: "tls_key_file", flag: "tls-key", usage: "TLS private key file",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.TLS.KeyFile) }},
+	{key: "tls_domains", flag: "tls-domains", usage: "comma-separated domains for autocert",
+		bind: func(c *Config) flag.Value { return (*listValue)(&c.TLS.Domains) }},
+	{key: "tls_cache_dir", flag: "tls-cache", def: "tmp/autocert", usage: "autocert certificate cache directory",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.TLS.CacheDir) }},
+	{key: "http_redirect_address", flag: "http-redirect", usage: "plain HTTP address redirecting to HTTPS",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.TLS.RedirectAddr) }},
+
+	{key: "database_dsn", flag: "d", usage: "PostgreSQL connection string, or sqlite://path for an SQLite file", redact: redactDSN,
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.DB.DSN) }},
+	{key: "redis_url", flag: "redis-url", usage: "Redis URL, used when no database DSN is set", redact: redactDSN,
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Redis.URL) }},
+	{key: "file_storage_path", flag: "f", def: "tmp/short-url-db.json", usage: "file storage path",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.DB.FileStorage) }},
+	{key: "reap_interval", flag: "reap-interval", def: "1m", usage: "how often expired URLs are purged, 0 disables",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.DB.ReapInterval) }},
+
+	// Secrets have no flags so that they do not show up in process
+	// listings.
+	{key: "secret_key", usage: "key signing auth tokens", redact: redactAll,
+		bind: func(c *Config) flag.Value { return (*bytesValue)(&c.Auth.Secret) }},
+	{key: "secret_keys", usage: "comma-separated id:secret signing keys, newest first", redact: redactKeys,
+		bind: func(c *Config) flag.Value { return (*keysValue)(&c.Auth.Keys) }},
+	{key: "secret_file", flag: "secret-file", def: "tmp/jwt-secret.key", usage: "where a generated secret is kept when none is set",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Auth.SecretFile) }},
+	{key: "token_expire", flag: "token-expire", def: "24h", usage: "auth token lifetime",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.Auth.TokenExpire) }},
+	{key: "token_refresh", flag: "token-refresh", def: "6h", usage: "refresh tokens used this close to expiry, 0 disables",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.Auth.TokenRefresh) }},
+
+	{key: "code_strategy", flag: "code-strategy", def: "hash", usage: "short code strategy: hash, random or counter",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Codes.Strategy) }},
+	{key: "code_alphabet", flag: "code-alphabet", usage: "short code alphabet",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Codes.Alphabet) }},
+	{key: "code_length", flag: "code-length", def: "8", usage: "short code length",
+		bind: func(c *Config) flag.Value { return (*intValue)(&c.Codes.Length) }},
+
+	{key: "alias_charset", flag: "alias-charset", def: defaultAliasCharset, usage: "characters allowed in custom aliases",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Aliases.Charset) }},
+	{key: "alias_min_length", flag: "alias-min-length", def: "3", usage: "minimum custom alias length",
+		bind: func(c *Config) flag.Value { return (*intValue)(&c.Aliases.MinLength) }},
+	{key: "alias_max_length", flag: "alias-max-length", def: "64", usage: "maximum custom alias length",
+		bind: func(c *Config) flag.Value { return (*intValue)(&c.Aliases.MaxLength) }},
+
+	{key: "click_ip_salt", flag: "click-ip-salt", usage: "key for hashing client IPs of clicks", redact: redactAll,
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Analytics.IPSalt) }},
+	{key: "click_ip_salt_file", flag: "click-ip-salt-file", def: "tmp/click-ip-salt.key", usage: "where a generated IP salt is kept when none is set",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Analytics.IPSaltFile) }},
+	{key: "click_buffer", flag: "click-buffer", def: "1024", usage: "number of clicks queued before new ones are dropped",
+		bind: func(c *Config) flag.Value { return (*intValue)(&c.Analytics.BufferSize) }},
+
+	{key: "cache_size", flag: "cache-size", def: "0", usage: "number of links cached in memory, 0 disables",
+		bind: func(c *Config) flag.Value { return (*intValue)(&c.Cache.Size) }},
+	{key: "cache_ttl", flag: "cache-ttl", def: "1m", usage: "how long a cached link is served",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.Cache.TTL) }},
+	{key: "cache_negative_ttl", flag: "cache-negative-ttl", def: "0", usage: "how long an unknown short code is remembered, 0 disables",
+		bind: func(c *Config) flag.Value { return (*durationValue)(&c.Cache.NegativeTTL) }},
+
+	{key: "trace_exporter", flag: "trace-exporter", usage: "trace exporter: otlp or stdout, empty disables tracing",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.Exporter) }},
+	{key: "trace_endpoint", flag: "trace-endpoint", usage: "OTLP endpoint URL such as http://localhost:4317",
+		bind: func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.Endpoint) }},
+}
+
+// rawValue holds a flag until the layers are merged.
+type rawValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (v *rawValue) String() string   { return v.value }
+func (v *rawValue) IsBoolFlag() bool { return v.isBool }
+func (v *rawValue) Set(s string) error {
+	v.value, v.set = s, true
+	return nil
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+type bytesValue []byte
+
+func (v *bytesValue) String() string { return string(*v) }
+func (v *bytesValue) Set(s string) error {
+	*v = []byte(s)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// listValue is a comma-separated list; blank items are dropped.
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+func (v *listValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+// keysValue is a list of id:secret pairs.
+type keysValue []model.SigningKey
+
+func (v *keysValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for _, k := range *v {
+		pairs = append(pairs, k.ID+":"+string(k.Secret))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v *keysValue) Set(s string) error {
+	*v = nil
+	seen := make(map[string]struct{})
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return errors.New("keys must be id:secret pairs")
+		}
+		if _, dup := seen[id]; dup {
+			return fmt.Errorf("duplicate key id %q", id)
+		}
+		seen[id] = struct{}{}
+		*v = append(*v, model.SigningKey{ID: id, Secret: []byte(secret)})
+	}
+	return nil
+}
+
+type addrValue struct {
+	host, port *string
+}
+
+func (v *addrValue) String() string { return *v.host + ":" + *v.port }
+func (v *addrValue) Set(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return fmt.Errorf("invalid address %q", s)
+	}
+	*v.host, *v.port = host, port
+	return nil
+}
+
+func redactAll(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+func redactKeys(v string) string {
+	if v == "" {
+		return ""
+	}
+	pairs := strings.Split(v, ",")
+	for i, p := range pairs {
+		id, _, _ := strings.Cut(p, ":")
+		pairs[i] = id + ":REDACTED"
+	}
+	return strings.Join(pairs, ",")
+}
+
+// redactDSN hides only the password so the rest stays useful.
+func redactDSN(v string) string {
+	if v == "" {
+		return ""
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" {
+		return redactAll(v)
+	}
+	return u.Redacted()
+}
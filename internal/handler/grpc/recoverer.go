@@ -0,0 +1,34 @@
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+
+	"shortener/internal/shared/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recoverer turns a panic in a handler into an Internal error, like
+// chi's middleware.Recoverer does for HTTP.
+func Recoverer(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.L().Error("panic",
+				logger.String("method", info.FullMethod),
+				logger.Any("recovered", rec),
+				logger.String("stack", string(debug.Stack())),
+			)
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shortener/internal/handler/grpc/pb"
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+	"shortener/internal/shared/metrics"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type URLService interface {
+	Ping(context.Context) error
+	GenerateShortURL(context.Context, string, string, model.ShortenRequest) (string, error)
+	GenerateShortBatch(context.Context, string, string, []model.ShortenBatchRequest) ([]model.ShortenBatchResponse, error)
+	OriginalURL(context.Context, string) (string, error)
+	UserStore(context.Context, string) ([]model.URLStore, error)
+	MakeDeleted(context.Context, model.DeleteURLsRequest)
+}
+
+type AuthService interface {
+	UserIDFromContext(context.Context) (string, bool)
+}
+
+type shortenerServer struct {
+	pb.UnimplementedShortenerServiceServer
+
+	log  *logger.Logger
+	svc  URLService
+	auth AuthService
+}
+
+func NewShortenerServer(log *logger.Logger, svc URLService, auth AuthService) *shortenerServer {
+	return &shortenerServer{log: log, svc: svc, auth: auth}
+}
+
+// Shorten answers AlreadyExists for a URL shortened before; the existing
+// short URL is attached as a ResourceInfo detail.
+func (s *shortenerServer) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	userID, ok := s.auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized user")
+	}
+	if req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty URL")
+	}
+
+	res, err := s.svc.GenerateShortURL(ctx, scheme(ctx), userID, model.ShortenRequest{
+		URL:       req.GetUrl(),
+		Alias:     req.GetAlias(),
+		ExpiresAt: timeOrNil(req.GetExpiresAt()),
+		TTL:       req.GetTtl(),
+	})
+	if err != nil {
+		if errors.Is(err, model.ErrURLAlreadyExists) {
+			st, derr := status.New(codes.AlreadyExists, err.Error()).
+				WithDetails(&errdetails.ResourceInfo{ResourceType: "url", ResourceName: res})
+			if derr != nil {
+				s.log.Error("Shorten", logger.Error(derr))
+				return nil, status.Error(codes.Internal, "internal error")
+			}
+			return nil, st.Err()
+		}
+		return nil, s.statusError("Shorten", err)
+	}
+
+	return &pb.ShortenResponse{Result: res}, nil
+}
+
+func (s *shortenerServer) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	userID, ok := s.auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized user")
+	}
+
+	elems := make([]model.ShortenBatchRequest, 0, len(req.GetItems()))
+	for _, it := range req.GetItems() {
+		elems = append(elems, model.ShortenBatchRequest{
+			CorrelationID: it.GetCorrelationId(),
+			Original:      it.GetOriginalUrl(),
+			Alias:         it.GetAlias(),
+			ExpiresAt:     timeOrNil(it.GetExpiresAt()),
+			TTL:           it.GetTtl(),
+		})
+	}
+
+	res, err := s.svc.GenerateShortBatch(ctx, scheme(ctx), userID, elems)
+	if err != nil {
+		return nil, s.statusError("ShortenBatch", err)
+	}
+
+	resp := &pb.ShortenBatchResponse{Items: make([]*pb.ShortenBatchResponse_Item, 0, len(res))}
+	for _, r := range res {
+		resp.Items = append(resp.Items, &pb.ShortenBatchResponse_Item{
+			CorrelationId: r.CorrelationID,
+			ShortUrl:      r.Short,
+		})
+	}
+
+	return resp, nil
+}
+
+// Expand resolves a short code like RedirectURL does, but without
+// recording a click.
+func (s *shortenerServer) Expand(ctx context.Context, req *pb.ExpandRequest) (*pb.ExpandResponse, error) {
+	if req.GetShort() == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty short code")
+	}
+
+	original, err := s.svc.OriginalURL(ctx, req.GetShort())
+	if err != nil {
+		return nil, s.statusError("Expand", err)
+	}
+	metrics.Redirects.Inc()
+
+	return &pb.ExpandResponse{OriginalUrl: original}, nil
+}
+
+func (s *shortenerServer) ListUserURLs(ctx context.Context, _ *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	userID, ok := s.auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized user")
+	}
+
+	urls, err := s.svc.UserStore(ctx, userID)
+	if err != nil {
+		return nil, s.statusError("ListUserURLs", err)
+	}
+
+	resp := &pb.ListUserURLsResponse{Items: make([]*pb.ListUserURLsResponse_Item, 0, len(urls))}
+	for _, u := range urls {
+		it := &pb.ListUserURLsResponse_Item{ShortUrl: u.Short, OriginalUrl: u.Original}
+		if u.ExpiresAt != nil {
+			it.ExpiresAt = timestamppb.New(*u.ExpiresAt)
+		}
+		resp.Items = append(resp.Items, it)
+	}
+
+	return resp, nil
+}
+
+// DeleteURLs queues the deletion and returns at once, like its HTTP
+// counterpart answering 202.
+func (s *shortenerServer) DeleteURLs(ctx context.Context, req *pb.DeleteURLsRequest) (*pb.DeleteURLsResponse, error) {
+	userID, ok := s.auth.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized user")
+	}
+
+	s.svc.MakeDeleted(ctx, model.DeleteURLsRequest{UserID: userID, URLs: req.GetShorts()})
+
+	return &pb.DeleteURLsResponse{}, nil
+}
+
+func (s *shortenerServer) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	if err := s.svc.Ping(ctx); err != nil {
+		s.log.Error("Ping", logger.Error(err))
+		return nil, status.Error(codes.Unavailable, "storage is unavailable")
+	}
+
+	return &pb.PingResponse{}, nil
+}
+
+// statusError maps service errors to status codes the way the HTTP
+// handlers map them to status lines. Gone links become NotFound with the
+// reason in the message.
+func (s *shortenerServer) statusError(op string, err error) error {
+	switch {
+	case errors.Is(err, model.ErrInvalidAlias), errors.Is(err, model.ErrInvalidExpiry):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrAliasTaken), errors.Is(err, model.ErrURLAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, model.ErrURLNotFound), errors.Is(err, model.ErrDeleted), errors.Is(err, model.ErrExpired):
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	s.log.Error(op, logger.Error(err))
+	return status.Error(codes.Internal, "internal error")
+}
+
+func scheme(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.AuthInfo != nil {
+		if _, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+func timeOrNil(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
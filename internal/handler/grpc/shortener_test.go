@@ -0,0 +1,196 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"shortener/internal/handler/grpc/pb"
+	"shortener/internal/model"
+	"shortener/internal/service"
+	"shortener/internal/shared/logger"
+	"shortener/internal/shared/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type urlServiceMock struct {
+	deleted []model.DeleteURLsRequest
+}
+
+func (s *urlServiceMock) Ping(context.Context) error { return nil }
+
+func (s *urlServiceMock) GenerateShortURL(
+	ctx context.Context,
+	scheme string,
+	userID string,
+	req model.ShortenRequest,
+) (string, error) {
+	switch req.URL {
+	case "https://dup.example.com":
+		return scheme + "://localhost/dup", model.ErrURLAlreadyExists
+	case "panic":
+		panic("boom")
+	}
+	if req.Alias == "api" {
+		return "", model.ErrInvalidAlias
+	}
+	return scheme + "://localhost/" + userID, nil
+}
+
+func (s *urlServiceMock) GenerateShortBatch(
+	ctx context.Context,
+	scheme string,
+	userID string,
+	elems []model.ShortenBatchRequest,
+) ([]model.ShortenBatchResponse, error) {
+	res := make([]model.ShortenBatchResponse, 0, len(elems))
+	for _, e := range elems {
+		res = append(res, model.ShortenBatchResponse{CorrelationID: e.CorrelationID, Short: "short-" + e.CorrelationID})
+	}
+	return res, nil
+}
+
+func (s *urlServiceMock) OriginalURL(ctx context.Context, short string) (string, error) {
+	switch short {
+	case "good":
+		return "https://example.com", nil
+	case "gone":
+		return "", model.ErrDeleted
+	}
+	return "", model.ErrURLNotFound
+}
+
+func (s *urlServiceMock) UserStore(ctx context.Context, userID string) ([]model.URLStore, error) {
+	return []model.URLStore{{UserID: userID, Short: "http://localhost/good", Original: "https://example.com"}}, nil
+}
+
+func (s *urlServiceMock) MakeDeleted(ctx context.Context, req model.DeleteURLsRequest) {
+	s.deleted = append(s.deleted, req)
+}
+
+func newTestClient(t *testing.T, svc URLService) pb.ShortenerServiceClient {
+	t.Helper()
+
+	auth, err := service.NewAuthService(logger.L(), []model.SigningKey{{Secret: []byte("secret")}}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		logger.InterceptorGRPC,
+		Recoverer,
+		auth.CheckInInterceptor,
+	))
+	pb.RegisterShortenerServiceServer(srv, NewShortenerServer(logger.L(), svc, auth))
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewShortenerServiceClient(conn)
+}
+
+func TestShortenerServer(t *testing.T) {
+	svc := &urlServiceMock{}
+	client := newTestClient(t, svc)
+	ctx := context.Background()
+
+	var header metadata.MD
+	resp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"}, grpc.Header(&header))
+	require.NoError(t, err)
+	tokens := header.Get("token")
+	require.Len(t, tokens, 1)
+	userID := resp.GetResult()[len("http://localhost/"):]
+
+	authed := metadata.AppendToOutgoingContext(ctx, "token", tokens[0])
+
+	t.Run("token keeps the user", func(t *testing.T) {
+		var header metadata.MD
+		resp, err := client.Shorten(authed, &pb.ShortenRequest{Url: "https://example.com"}, grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost/"+userID, resp.GetResult())
+		assert.Empty(t, header.Get("token"))
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		bad := metadata.AppendToOutgoingContext(ctx, "token", "garbage")
+		_, err := client.Shorten(bad, &pb.ShortenRequest{Url: "https://example.com"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("duplicate carries existing short URL", func(t *testing.T) {
+		_, err := client.Shorten(authed, &pb.ShortenRequest{Url: "https://dup.example.com"})
+		st := status.Convert(err)
+		require.Equal(t, codes.AlreadyExists, st.Code())
+		require.Len(t, st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.ResourceInfo)
+		require.True(t, ok)
+		assert.Equal(t, "http://localhost/dup", info.GetResourceName())
+	})
+
+	t.Run("invalid alias", func(t *testing.T) {
+		_, err := client.Shorten(authed, &pb.ShortenRequest{Url: "https://example.com", Alias: "api"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("panic is recovered", func(t *testing.T) {
+		_, err := client.Shorten(authed, &pb.ShortenRequest{Url: "panic"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		resp, err := client.ShortenBatch(authed, &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchRequest_Item{
+			{CorrelationId: "1", OriginalUrl: "https://example.com/1"},
+			{CorrelationId: "2", OriginalUrl: "https://example.com/2"},
+		}})
+		require.NoError(t, err)
+		require.Len(t, resp.GetItems(), 2)
+		assert.Equal(t, "short-2", resp.GetItems()[1].GetShortUrl())
+	})
+
+	t.Run("expand", func(t *testing.T) {
+		redirects := testutil.ToFloat64(metrics.Redirects)
+		resp, err := client.Expand(authed, &pb.ExpandRequest{Short: "good"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", resp.GetOriginalUrl())
+		assert.Equal(t, redirects+1, testutil.ToFloat64(metrics.Redirects))
+
+		_, err = client.Expand(authed, &pb.ExpandRequest{Short: "gone"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.Expand(authed, &pb.ExpandRequest{Short: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		resp, err := client.ListUserURLs(authed, &pb.ListUserURLsRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.GetItems(), 1)
+
+		_, err = client.DeleteURLs(authed, &pb.DeleteURLsRequest{Shorts: []string{"good"}})
+		require.NoError(t, err)
+		require.Len(t, svc.deleted, 1)
+		assert.Equal(t, model.DeleteURLsRequest{UserID: userID, URLs: []string{"good"}}, svc.deleted[0])
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		_, err := client.Ping(ctx, &pb.PingRequest{})
+		require.NoError(t, err)
+	})
+}
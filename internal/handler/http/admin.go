@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminService interface {
+	Lookup(ctx context.Context, actor, short, original string) (model.AdminURL, error)
+	UserURLs(ctx context.Context, actor, userID string) ([]model.AdminURL, error)
+	Disable(ctx context.Context, actor, short string) error
+	Restore(ctx context.Context, actor, short string) error
+	Transfer(ctx context.Context, actor, short, to string) error
+	AuditLog(ctx context.Context, actor string, limit int) ([]model.AuditEntry, error)
+}
+
+type adminHandler struct {
+	log  *logger.Logger
+	svc  AdminService
+	auth AuthService
+}
+
+func NewAdminHandler(log *logger.Logger, svc AdminService, auth AuthService) *adminHandler {
+	return &adminHandler{log: log, svc: svc, auth: auth}
+}
+
+// Lookup serves GET /api/admin/urls?short= or ?original=.
+func (h *adminHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	res, err := h.svc.Lookup(r.Context(), actor, q.Get("short"), q.Get("original"))
+	if err != nil {
+		h.writeError(w, "Lookup", err)
+		return
+	}
+	h.writeJSON(w, "Lookup", res)
+}
+
+// UserURLs serves GET /api/admin/users/{id}/urls.
+func (h *adminHandler) UserURLs(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.svc.UserURLs(r.Context(), actor, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, "UserURLs", err)
+		return
+	}
+	h.writeJSON(w, "UserURLs", res)
+}
+
+// Disable serves POST /api/admin/urls/{short}/disable.
+func (h *adminHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Disable(r.Context(), actor, chi.URLParam(r, "short")); err != nil {
+		h.writeError(w, "Disable", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restore serves POST /api/admin/urls/{short}/restore.
+func (h *adminHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Restore(r.Context(), actor, chi.URLParam(r, "short")); err != nil {
+		h.writeError(w, "Restore", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Transfer serves POST /api/admin/urls/{short}/transfer.
+func (h *adminHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.Transfer(r.Context(), actor, chi.URLParam(r, "short"), req.UserID); err != nil {
+		h.writeError(w, "Transfer", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuditLog serves GET /api/admin/audit?limit=.
+func (h *adminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	actor, ok := h.actor(w, r)
+	if !ok {
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	res, err := h.svc.AuditLog(r.Context(), actor, limit)
+	if err != nil {
+		h.writeError(w, "AuditLog", err)
+		return
+	}
+	h.writeJSON(w, "AuditLog", res)
+}
+
+func (h *adminHandler) actor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("admin", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+	return actor, ok
+}
+
+func (h *adminHandler) writeError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, model.ErrInvalidAdminReq):
+		writeJSONError(w, http.StatusBadRequest, err)
+	case errors.Is(err, model.ErrForbidden):
+		writeJSONError(w, http.StatusForbidden, err)
+	case errors.Is(err, model.ErrURLNotFound):
+		writeJSONError(w, http.StatusNotFound, err)
+	default:
+		h.log.Error(op, logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}
+}
+
+func (h *adminHandler) writeJSON(w http.ResponseWriter, op string, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Error(op, logger.Error(err))
+	}
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyService interface {
+	Create(context.Context, string, model.APIKeyRequest) (model.APIKeyResponse, error)
+	List(context.Context, string) ([]model.APIKey, error)
+	Revoke(context.Context, string, string) error
+}
+
+type apiKeyHandler struct {
+	log  *logger.Logger
+	svc  APIKeyService
+	auth AuthService
+}
+
+func NewAPIKeyHandler(log *logger.Logger, svc APIKeyService, auth AuthService) *apiKeyHandler {
+	return &apiKeyHandler{log: log, svc: svc, auth: auth}
+}
+
+// Create serves POST /api/user/keys. The key is in the response only.
+func (h *apiKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("CreateAPIKey", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req model.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.svc.Create(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidAPIKeyReq) {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		h.log.Error("CreateAPIKey", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.log.Error("CreateAPIKey", logger.Error(err))
+	}
+}
+
+// List serves GET /api/user/keys.
+func (h *apiKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("ListAPIKeys", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.svc.List(r.Context(), userID)
+	if err != nil {
+		h.log.Error("ListAPIKeys", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		h.log.Error("ListAPIKeys", logger.Error(err))
+	}
+}
+
+// Revoke serves DELETE /api/user/keys/{id}.
+func (h *apiKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("RevokeAPIKey", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.svc.Revoke(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			writeJSONError(w, http.StatusNotFound, err)
+			return
+		}
+		h.log.Error("RevokeAPIKey", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+)
+
+type TokenService interface {
+	AuthService
+	RoleFromContext(context.Context) string
+	IssueToken(userID, role string) (string, time.Time, error)
+	SignIn(w http.ResponseWriter, userID, role string) (string, time.Time, error)
+	SignOut(http.ResponseWriter)
+}
+
+type AccountService interface {
+	Register(context.Context, string, model.Credentials) (model.User, error)
+	Login(context.Context, string, model.Credentials) (model.User, int, error)
+}
+
+type authHandler struct {
+	log      *logger.Logger
+	auth     TokenService
+	accounts AccountService
+}
+
+func NewAuthHandler(log *logger.Logger, auth TokenService, accounts AccountService) *authHandler {
+	return &authHandler{log: log, auth: auth, accounts: accounts}
+}
+
+// Token serves POST /api/auth/token: a fresh token for the current
+// user, for clients that authenticate with a bearer header.
+func (h *authHandler) Token(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("Token", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, expiresAt, err := h.auth.IssueToken(userID, h.auth.RoleFromContext(r.Context()))
+	if err != nil {
+		h.log.Error("Token", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(model.TokenResponse{Token: token, ExpiresAt: expiresAt}); err != nil {
+		h.log.Error("Token", logger.Error(err))
+	}
+}
+
+// Register serves POST /api/auth/register. The account keeps the links
+// of the anonymous user making the request.
+func (h *authHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var c model.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	current, _ := h.auth.UserIDFromContext(r.Context())
+	u, err := h.accounts.Register(r.Context(), current, c)
+	switch {
+	case errors.Is(err, model.ErrInvalidEmail), errors.Is(err, model.ErrInvalidPassword):
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, model.ErrUserExists):
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		h.log.Error("Register", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.signIn(w, "Register", http.StatusCreated, u.Role, model.AccountResponse{UserID: u.ID, Email: u.Email})
+}
+
+// Login serves POST /api/auth/login. Links of the anonymous user making
+// the request move into the account.
+func (h *authHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var c model.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	current, _ := h.auth.UserIDFromContext(r.Context())
+	u, claimed, err := h.accounts.Login(r.Context(), current, c)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			writeJSONError(w, http.StatusUnauthorized, err)
+			return
+		}
+		h.log.Error("Login", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if claimed > 0 {
+		h.log.Info("links claimed", logger.String("user_id", u.ID), logger.Int("count", claimed))
+	}
+
+	h.signIn(w, "Login", http.StatusOK, u.Role, model.AccountResponse{UserID: u.ID, Email: u.Email, Role: u.Role, Claimed: claimed})
+}
+
+// Logout serves POST /api/auth/logout by dropping the token cookie.
+func (h *authHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	h.auth.SignOut(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// signIn sends a token for resp.UserID with role along with resp.
+func (h *authHandler) signIn(w http.ResponseWriter, op string, status int, role string, resp model.AccountResponse) {
+	token, expiresAt, err := h.auth.SignIn(w, resp.UserID, role)
+	if err != nil {
+		h.log.Error(op, logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	resp.Token, resp.ExpiresAt = token, expiresAt
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.log.Error(op, logger.Error(err))
+	}
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenServiceMock struct {
+	authServiceMock
+	expiresAt time.Time
+	signedIn  string
+}
+
+func (s *tokenServiceMock) RoleFromContext(context.Context) string { return "" }
+
+func (s *tokenServiceMock) IssueToken(userID, role string) (string, time.Time, error) {
+	return "token-for-" + userID, s.expiresAt, nil
+}
+
+func (s *tokenServiceMock) SignIn(w http.ResponseWriter, userID, role string) (string, time.Time, error) {
+	s.signedIn = userID
+	return s.IssueToken(userID, role)
+}
+
+func (s *tokenServiceMock) SignOut(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: "token", MaxAge: -1})
+}
+
+// accountServiceMock knows one account, ann@example.com with password
+// "password", which has the ID "acc".
+type accountServiceMock struct{}
+
+func (s *accountServiceMock) Register(ctx context.Context, current string, c model.Credentials) (model.User, error) {
+	switch {
+	case c.Email == "ann@example.com":
+		return model.User{}, model.ErrUserExists
+	case !strings.Contains(c.Email, "@"):
+		return model.User{}, model.ErrInvalidEmail
+	}
+	return model.User{ID: current, Email: c.Email}, nil
+}
+
+func (s *accountServiceMock) Login(ctx context.Context, current string, c model.Credentials) (model.User, int, error) {
+	if c.Email != "ann@example.com" || c.Password != "password" {
+		return model.User{}, 0, model.ErrInvalidCredentials
+	}
+	return model.User{ID: "acc", Email: c.Email}, 2, nil
+}
+
+func TestAuthHandlerToken(t *testing.T) {
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewAuthHandler(logger.L(), &tokenServiceMock{expiresAt: exp}, &accountServiceMock{})
+
+	w := httptest.NewRecorder()
+	h.Token(w, httptest.NewRequest(http.MethodPost, "/api/auth/token", nil))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var resp model.TokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "token-for-1", resp.Token)
+	assert.True(t, exp.Equal(resp.ExpiresAt))
+}
+
+func TestAuthHandlerAccounts(t *testing.T) {
+	testCases := []struct {
+		name       string
+		login      bool
+		body       string
+		wantStatus int
+		wantUser   string
+		wantClaim  int
+	}{
+		{name: "register", body: `{"email":"bob@example.com","password":"password"}`, wantStatus: http.StatusCreated, wantUser: "1"},
+		{name: "register taken email", body: `{"email":"ann@example.com","password":"password"}`, wantStatus: http.StatusConflict},
+		{name: "register invalid email", body: `{"email":"bob","password":"password"}`, wantStatus: http.StatusBadRequest},
+		{name: "register bad json", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "login", login: true, body: `{"email":"ann@example.com","password":"password"}`, wantStatus: http.StatusOK, wantUser: "acc", wantClaim: 2},
+		{name: "login wrong password", login: true, body: `{"email":"ann@example.com","password":"nope"}`, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := &tokenServiceMock{expiresAt: time.Now().Add(time.Hour)}
+			h := NewAuthHandler(logger.L(), tokens, &accountServiceMock{})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(tc.body))
+			if tc.login {
+				h.Login(w, r)
+			} else {
+				h.Register(w, r)
+			}
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			assert.Equal(t, tc.wantUser, tokens.signedIn)
+			if tc.wantUser == "" {
+				return
+			}
+
+			var resp model.AccountResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tc.wantUser, resp.UserID)
+			assert.Equal(t, "token-for-"+tc.wantUser, resp.Token)
+			assert.Equal(t, tc.wantClaim, resp.Claimed)
+		})
+	}
+}
+
+func TestAuthHandlerLogout(t *testing.T) {
+	h := NewAuthHandler(logger.L(), &tokenServiceMock{}, &accountServiceMock{})
+
+	w := httptest.NewRecorder()
+	h.Logout(w, httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+}
@@ -5,17 +5,19 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"shortener/internal/model"
 	"shortener/internal/shared/logger"
+	"shortener/internal/shared/metrics"
 )
 
 type URLService interface {
 	Ping(context.Context) error
-	GenerateShortURL(context.Context, string, string, string) (string, error)
+	GenerateShortURL(context.Context, string, string, model.ShortenRequest) (string, error)
 	GenerateShortBatch(context.Context, string, string, []model.ShortenBatchRequest) ([]model.ShortenBatchResponse, error)
 	OriginalURL(context.Context, string) (string, error)
 	URLByID(context.Context, int) (model.URLStore, error)
@@ -27,14 +29,19 @@ type AuthService interface {
 	UserIDFromContext(context.Context) (string, bool)
 }
 
+type ClickService interface {
+	Record(model.Click, string)
+}
+
 type urlHandler struct {
-	log  *logger.Logger
-	svc  URLService
-	auth AuthService
+	log    *logger.Logger
+	svc    URLService
+	auth   AuthService
+	clicks ClickService
 }
 
-func NewURLHandler(log *logger.Logger, svc URLService, auth AuthService) *urlHandler {
-	return &urlHandler{log: log, svc: svc, auth: auth}
+func NewURLHandler(log *logger.Logger, svc URLService, auth AuthService, clicks ClickService) *urlHandler {
+	return &urlHandler{log: log, svc: svc, auth: auth, clicks: clicks}
 }
 
 func (h *urlHandler) URLByID(w http.ResponseWriter, r *http.Request) {
@@ -47,7 +54,7 @@ func (h *urlHandler) URLByID(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.svc.URLByID(r.Context(), urlID)
 	if err != nil {
-		if errors.Is(err, model.ErrDeleted) {
+		if errors.Is(err, model.ErrDeleted) || errors.Is(err, model.ErrExpired) {
 			w.WriteHeader(http.StatusGone)
 			return
 		}
@@ -69,7 +76,7 @@ func (h *urlHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	shortURL := strings.Trim(r.URL.Path, "/")
 	originalURL, err := h.svc.OriginalURL(r.Context(), shortURL)
 	if err != nil {
-		if errors.Is(err, model.ErrDeleted) {
+		if errors.Is(err, model.ErrDeleted) || errors.Is(err, model.ErrExpired) {
 			w.WriteHeader(http.StatusGone)
 			return
 		}
@@ -78,6 +85,15 @@ func (h *urlHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.clicks.Record(model.Click{
+		Short:          shortURL,
+		Referrer:       r.Referer(),
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Country:        clickCountry(r),
+	}, clientIP(r))
+	metrics.Redirects.Inc()
+
 	http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
 }
 
@@ -132,7 +148,7 @@ func (h *urlHandler) ShortenURLText(w http.ResponseWriter, r *http.Request) {
 		scheme = "https"
 	}
 
-	resp, err := h.svc.GenerateShortURL(r.Context(), scheme, userID, originalURL)
+	resp, err := h.svc.GenerateShortURL(r.Context(), scheme, userID, model.ShortenRequest{URL: originalURL})
 	if err != nil {
 		if errors.Is(err, model.ErrURLAlreadyExists) {
 			w.Header().Set("Content-Type", "text/plain")
@@ -181,8 +197,11 @@ func (h *urlHandler) ShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 		scheme = "https"
 	}
 
-	resp, err := h.svc.GenerateShortURL(r.Context(), scheme, userID, urlRecv.URL)
+	resp, err := h.svc.GenerateShortURL(r.Context(), scheme, userID, urlRecv)
 	if err != nil {
+		if h.writeRequestError(w, err) {
+			return
+		}
 		if errors.Is(err, model.ErrURLAlreadyExists) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusConflict)
@@ -238,6 +257,9 @@ func (h *urlHandler) ShortenBatchJSON(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.svc.GenerateShortBatch(r.Context(), scheme, userID, urlRecv)
 	if err != nil {
+		if h.writeRequestError(w, err) {
+			return
+		}
 		h.log.Error("ShortenBatchJSON", logger.Error(err))
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
@@ -294,6 +316,54 @@ func (h *urlHandler) PingDB(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// writeRequestError answers shorten requests rejected because of their
+// alias or expiry and reports whether it did.
+func (h *urlHandler) writeRequestError(w http.ResponseWriter, err error) bool {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, model.ErrAliasTaken):
+		status = http.StatusConflict
+	case !errors.Is(err, model.ErrInvalidAlias) && !errors.Is(err, model.ErrInvalidExpiry):
+		return false
+	}
+
+	writeJSONError(w, status, err)
+	return true
+}
+
+// clientIP prefers the address set by the reverse proxy in X-Real-IP
+// and falls back to the peer address.
+func clientIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clickCountry takes the country set by a CDN in CF-IPCountry and
+// otherwise guesses it from the region of the preferred language,
+// e.g. "de-AT,de;q=0.9" gives "AT".
+func clickCountry(r *http.Request) string {
+	if c := r.Header.Get("CF-IPCountry"); len(c) == 2 {
+		return strings.ToUpper(c)
+	}
+
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	parts := strings.Split(strings.TrimSpace(lang), "-")
+	for _, p := range parts[1:] {
+		if len(p) == 2 {
+			return strings.ToUpper(p)
+		}
+	}
+	return ""
+}
+
 func readBody(r *http.Request) ([]byte, error) {
 	b, err := io.ReadAll(r.Body)
 	if err != nil || len(b) == 0 {
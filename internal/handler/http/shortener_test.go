@@ -25,8 +25,13 @@ const (
 type urlServiceMock struct{}
 
 // TODO: test json, test gzip, test ping
-func (s *urlServiceMock) GenerateShortURL(ctx context.Context, scheme, userID, original string) (string, error) {
-	if original == "wrong" {
+func (s *urlServiceMock) GenerateShortURL(
+	ctx context.Context,
+	scheme string,
+	userID string,
+	req model.ShortenRequest,
+) (string, error) {
+	if req.URL == "wrong" {
 		return "", errors.New("service error")
 	}
 	return fmt.Sprintf("http://%s/%s", addr, good), nil
@@ -66,6 +71,12 @@ func (s *authServiceMock) UserIDFromContext(context.Context) (string, bool) {
 	return "1", true
 }
 
+type clickServiceMock struct{ clicks []model.Click }
+
+func (s *clickServiceMock) Record(c model.Click, ip string) {
+	s.clicks = append(s.clicks, c)
+}
+
 func TestURLHandler(t *testing.T) {
 	type want struct {
 		statusCode int
@@ -95,7 +106,8 @@ func TestURLHandler(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			h := NewURLHandler(logger.L(), &urlServiceMock{}, &authServiceMock{})
+			clicks := &clickServiceMock{}
+			h := NewURLHandler(logger.L(), &urlServiceMock{}, &authServiceMock{}, clicks)
 
 			w := httptest.NewRecorder()
 			body := bytes.NewBuffer([]byte(tc.body))
@@ -125,6 +137,7 @@ func TestURLHandler(t *testing.T) {
 			res = w.Result()
 
 			assert.Equal(t, tc.getStatusCode, res.StatusCode)
+			assert.Len(t, clicks.clicks, 1)
 		})
 	}
 }
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StatsService interface {
+	LinkStats(context.Context, string, string, model.StatsFilter) (model.LinkStats, error)
+	ServiceStats(context.Context) (model.ServiceStats, error)
+}
+
+type statsHandler struct {
+	log  *logger.Logger
+	svc  StatsService
+	auth AuthService
+}
+
+func NewStatsHandler(log *logger.Logger, svc StatsService, auth AuthService) *statsHandler {
+	return &statsHandler{log: log, svc: svc, auth: auth}
+}
+
+// LinkStats serves GET /api/user/urls/{short}/stats?from=&to=&bucket=.
+// from and to accept RFC 3339 timestamps or plain dates.
+func (h *statsHandler) LinkStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.auth.UserIDFromContext(r.Context())
+	if !ok {
+		h.log.Error("LinkStats", logger.ErrorS("unauthorized user"))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	from, err := parseStatsTime(q.Get("from"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("from: %w", err))
+		return
+	}
+	to, err := parseStatsTime(q.Get("to"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("to: %w", err))
+		return
+	}
+
+	res, err := h.svc.LinkStats(r.Context(), userID, chi.URLParam(r, "short"), model.StatsFilter{
+		From:   from,
+		To:     to,
+		Bucket: q.Get("bucket"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidStats):
+			writeJSONError(w, http.StatusBadRequest, err)
+		case errors.Is(err, model.ErrForbidden):
+			writeJSONError(w, http.StatusForbidden, err)
+		case errors.Is(err, model.ErrURLNotFound):
+			writeJSONError(w, http.StatusNotFound, err)
+		case errors.Is(err, model.ErrDeleted), errors.Is(err, model.ErrExpired):
+			writeJSONError(w, http.StatusGone, err)
+		default:
+			h.log.Error("LinkStats", logger.Error(err))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.log.Error("LinkStats", logger.Error(err))
+	}
+}
+
+// ServiceStats serves GET /api/internal/stats. Access is limited by the
+// TrustedSubnet middleware.
+func (h *statsHandler) ServiceStats(w http.ResponseWriter, r *http.Request) {
+	res, err := h.svc.ServiceStats(r.Context())
+	if err != nil {
+		h.log.Error("ServiceStats", logger.Error(err))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.log.Error("ServiceStats", logger.Error(err))
+	}
+}
+
+func parseStatsTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.ErrorResponse{Error: err.Error()})
+}
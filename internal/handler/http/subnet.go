@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"shortener/internal/model"
+)
+
+// TrustedSubnet lets through only clients whose address, as reported by
+// clientIP, belongs to subnet. A nil subnet denies everyone.
+func TrustedSubnet(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(clientIP(r))
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				writeJSONError(w, http.StatusForbidden, model.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name       string
+		subnet     *net.IPNet
+		remoteAddr string
+		realIP     string
+		want       int
+	}{
+		{"remote inside", subnet, "10.1.2.3:5000", "", http.StatusOK},
+		{"remote outside", subnet, "192.168.0.1:5000", "", http.StatusForbidden},
+		{"real ip inside", subnet, "192.168.0.1:5000", "10.9.9.9", http.StatusOK},
+		{"real ip outside", subnet, "10.1.2.3:5000", "8.8.8.8", http.StatusForbidden},
+		{"garbage real ip", subnet, "10.1.2.3:5000", "nope", http.StatusForbidden},
+		{"no subnet", nil, "10.1.2.3:5000", "", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+			w := httptest.NewRecorder()
+
+			TrustedSubnet(tc.subnet)(ok).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
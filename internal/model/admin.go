@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Audited admin actions.
+const (
+	AuditLookup    = "lookup"
+	AuditUserURLs  = "list_user_urls"
+	AuditDisable   = "disable"
+	AuditRestore   = "restore"
+	AuditTransfer  = "transfer"
+	AuditReadAudit = "read_audit"
+)
+
+// AuditEntry records one admin action. Target is the link or user acted
+// on; Detail holds anything else needed to understand the action, such
+// as the new owner of a transferred link.
+type AuditEntry struct {
+	ID     int64     `json:"id"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// AdminURL shows a link to admins, including its state.
+type AdminURL struct {
+	URLStore
+	Deleted bool `json:"is_deleted"`
+	Expired bool `json:"is_expired"`
+}
+
+type TransferRequest struct {
+	UserID string `json:"user_id"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Scopes an API key can be granted.
+const (
+	ScopeShorten = "shorten"
+	ScopeRead    = "read"
+	ScopeDelete  = "delete"
+	ScopeStats   = "stats"
+)
+
+var Scopes = []string{ScopeShorten, ScopeRead, ScopeDelete, ScopeStats}
+
+// APIKey is a long-lived credential of a user. Only the SHA-256 hash of
+// the key is stored; Prefix is kept so users can tell their keys apart.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Expired reports whether the key has reached its expiry at now.
+func (k APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyResponse is returned once, on creation: Key is not stored and
+// cannot be shown again.
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -1,8 +1,30 @@
 package model
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RoleAdmin may use the moderation API under /api/admin.
+const RoleAdmin = "admin"
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string `json:"user_id"`
+	Role   string `json:"role,omitempty"`
+}
+
+// SigningKey is an HMAC key for auth tokens. ID is sent as the token's
+// kid header so the key can be found again after rotation.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// TokenResponse hands an auth token to API clients, which send it back
+// as "Authorization: Bearer <token>".
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
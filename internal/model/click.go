@@ -0,0 +1,69 @@
+package model
+
+import "time"
+
+// Click is a single redirect served for a short code. The client IP is
+// never stored, only its keyed hash.
+type Click struct {
+	Time           time.Time `json:"time"`
+	Short          string    `json:"short_url"`
+	Referrer       string    `json:"referrer,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	IPHash         string    `json:"ip_hash,omitempty"`
+	AcceptLanguage string    `json:"accept_language,omitempty"`
+	Country        string    `json:"country,omitempty"`
+}
+
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+)
+
+// StatsFilter selects the clicks in [From, To) and how they are grouped.
+type StatsFilter struct {
+	From   time.Time
+	To     time.Time
+	Bucket string
+	Top    int
+}
+
+// BucketStart returns the start of the bucket t falls into, in UTC.
+func (f StatsFilter) BucketStart(t time.Time) time.Time {
+	t = t.UTC()
+	if f.Bucket == BucketHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// BucketSize returns the length of one bucket.
+func (f StatsFilter) BucketSize() time.Duration {
+	if f.Bucket == BucketHour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+type LinkStats struct {
+	Short          string       `json:"short_url"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"`
+	Bucket         string       `json:"bucket"`
+	TotalClicks    int          `json:"total_clicks"`
+	UniqueVisitors int          `json:"unique_visitors"`
+	Series         []StatsPoint `json:"series"`
+	TopReferrers   []StatsEntry `json:"top_referrers"`
+	TopCountries   []StatsEntry `json:"top_countries"`
+	TopUserAgents  []StatsEntry `json:"top_user_agents"`
+}
+
+type StatsPoint struct {
+	Time           time.Time `json:"time"`
+	Clicks         int       `json:"clicks"`
+	UniqueVisitors int       `json:"unique_visitors"`
+}
+
+type StatsEntry struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
@@ -8,4 +8,22 @@ var (
 	ErrURLNotFound      = errors.New("URL not found")
 	ErrURLAlreadyExists = errors.New("URL already exists")
 	ErrDeleted          = errors.New("URL was deleted")
+	ErrShortExists      = errors.New("short URL is taken by another URL")
+	ErrAliasTaken       = errors.New("alias is taken by another URL")
+	ErrInvalidAlias     = errors.New("invalid alias")
+	ErrExpired          = errors.New("URL has expired")
+	ErrInvalidExpiry    = errors.New("invalid expiry")
+	ErrForbidden        = errors.New("access denied")
+	ErrInvalidStats     = errors.New("invalid stats query")
+
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("email is already registered")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrInvalidPassword    = errors.New("password must be 8 to 72 bytes long")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+
+	ErrAPIKeyNotFound   = errors.New("API key not found")
+	ErrInvalidAPIKeyReq = errors.New("invalid API key request")
+
+	ErrInvalidAdminReq = errors.New("invalid admin request")
 )
@@ -1,15 +1,28 @@
 package model
 
+import "time"
+
 type URLStore struct {
-	UUID        int    `json:"uuid"`
-	UserID      string `json:"user_id"`
-	Short       string `json:"short_url"`
-	Original    string `json:"original_url"`
-	DeletedFlag bool   `json:"-"`
+	UUID        int        `json:"uuid"`
+	UserID      string     `json:"user_id"`
+	Short       string     `json:"short_url"`
+	Original    string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DeletedFlag bool       `json:"-"`
+}
+
+// Expired reports whether the link has reached its expiry at now.
+func (u URLStore) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
 }
 
+// ShortenRequest asks for a short link. ExpiresAt and TTL (seconds) are
+// mutually exclusive ways to limit the link's lifetime.
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       int64      `json:"ttl,omitempty"`
 }
 
 type ShortenResponse struct {
@@ -17,8 +30,17 @@ type ShortenResponse struct {
 }
 
 type ShortenBatchRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	Original      string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	Original      string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTL           int64      `json:"ttl,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Reason is a machine-readable cause, e.g. "token_expired".
+	Reason string `json:"reason,omitempty"`
 }
 
 type ShortenBatchResponse struct {
@@ -26,6 +48,16 @@ type ShortenBatchResponse struct {
 	Short         string `json:"short_url"`
 }
 
+// ServiceStats are service-wide counters. Live links are neither
+// deleted nor expired; expired ones are counted until they are reaped.
+type ServiceStats struct {
+	URLs    int `json:"urls"`
+	Users   int `json:"users"`
+	Live    int `json:"live"`
+	Deleted int `json:"deleted"`
+	Expired int `json:"expired"`
+}
+
 type DeleteURLsRequest struct {
 	UserID string
 	URLs   []string
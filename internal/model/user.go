@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// User is a registered account. Its ID is the UserID that owns links,
+// the same kind of ID anonymous users get.
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// AccountResponse answers register and login. Claimed is the number of
+// anonymous links moved into the account by this login.
+type AccountResponse struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role,omitempty"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Claimed   int       `json:"claimed,omitempty"`
+}
@@ -0,0 +1,251 @@
+// Package cache serves link reads from memory in front of any
+// URLRepository. Writes go through to the wrapped repository and evict
+// what they change, so the cache stays exact as long as every write to
+// the repository passes through it. Writes made elsewhere, such as by
+// another replica sharing the repository, show up once the entry
+// expires. Unknown short codes are only cached with a negative TTL,
+// which delays a link created elsewhere by up to that TTL.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+type entry struct {
+	key     string
+	url     model.URLStore
+	err     error
+	expires time.Time
+	// gen is the write generation the entry was read in.
+	gen uint64
+}
+
+type urlRepository struct {
+	service.URLRepository
+
+	size int
+	ttl  time.Duration
+	// negativeTTL is how long a not-found result is kept; zero keeps
+	// none.
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu sync.Mutex
+	// order holds the entries, most recently used first.
+	order *list.List
+	items map[string]*list.Element
+	// gen counts writes. A read that overlaps a write is not cached, and
+	// entries by ID are dropped once anything is written, since a write
+	// does not say which IDs it touched.
+	gen uint64
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewURLRepository caches up to size results of Get and GetByID from
+// repo for ttl, and those that found nothing for negativeTTL.
+func NewURLRepository(repo service.URLRepository, size int, ttl, negativeTTL time.Duration) *urlRepository {
+	return &urlRepository{
+		URLRepository: repo,
+		size:          size,
+		ttl:           ttl,
+		negativeTTL:   negativeTTL,
+		now:           time.Now,
+		order:         list.New(),
+		items:         make(map[string]*list.Element),
+	}
+}
+
+func (c *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
+	return c.load(ctx, shortKey(short), false, func(ctx context.Context) (model.URLStore, error) {
+		return c.URLRepository.Get(ctx, short)
+	})
+}
+
+func (c *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	return c.load(ctx, "id:"+strconv.Itoa(uuid), true, func(ctx context.Context) (model.URLStore, error) {
+		return c.URLRepository.GetByID(ctx, uuid)
+	})
+}
+
+func (c *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
+	defer c.Invalidate(u.Short)
+	return c.URLRepository.Save(ctx, u)
+}
+
+func (c *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	defer func() {
+		for _, u := range urls {
+			c.Invalidate(u.Short)
+		}
+	}()
+	return c.URLRepository.SaveAll(ctx, urls)
+}
+
+func (c *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	defer func() {
+		for _, short := range urls {
+			c.Invalidate(short)
+		}
+	}()
+	return c.URLRepository.DeleteBatch(ctx, userID, urls)
+}
+
+// DeleteExpired also evicts the links it may have purged, so that they
+// read as not found rather than expired.
+func (c *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	defer c.evictIf(func(e *entry) bool {
+		return errors.Is(e.err, model.ErrExpired) || e.url.Expired(before)
+	})
+	return c.URLRepository.DeleteExpired(ctx, before)
+}
+
+func (c *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	defer c.evictIf(func(e *entry) bool { return e.url.UserID == from })
+	return c.URLRepository.ReassignUser(ctx, from, to)
+}
+
+// Invalidate evicts the link short. Writes made around the cache, such
+// as admin actions, must call it.
+func (c *urlRepository) Invalidate(short string) {
+	key := shortKey(short)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.group.Forget(key)
+}
+
+// Counts returns how many reads were served from the cache and how many
+// went to the repository.
+func (c *urlRepository) Counts() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// load returns the cached result for key or fetches it, once for all
+// concurrent callers. The fetch outlives a caller that gives up so that
+// the others still get the result.
+func (c *urlRepository) load(ctx context.Context, key string, byID bool, fetch func(context.Context) (model.URLStore, error)) (model.URLStore, error) {
+	span := trace.SpanFromContext(ctx)
+	if e, ok := c.lookup(key, byID); ok {
+		c.hits.Add(1)
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return e.url, e.err
+	}
+	c.misses.Add(1)
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		gen := c.generation()
+		u, err := fetch(context.WithoutCancel(ctx))
+		c.store(key, gen, u, err)
+		return u, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return model.URLStore{}, ctx.Err()
+	case res := <-ch:
+		u, _ := res.Val.(model.URLStore)
+		return u, res.Err
+	}
+}
+
+func (c *urlRepository) lookup(key string, byID bool) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) || byID && e.gen != c.gen {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+
+	return e, true
+}
+
+// store caches the result of a read made in generation gen. Only links
+// and the errors that describe a link's state are kept. A not-found
+// result is stale as soon as another replica issues the code, so it is
+// kept for negativeTTL only; local saves evict it.
+func (c *urlRepository) store(key string, gen uint64, u model.URLStore, err error) {
+	now := c.now()
+	expires := now.Add(c.ttl)
+	switch {
+	case err == nil:
+		if u.ExpiresAt != nil && u.ExpiresAt.Before(expires) {
+			expires = *u.ExpiresAt
+		}
+	case errors.Is(err, model.ErrDeleted), errors.Is(err, model.ErrExpired):
+	case errors.Is(err, model.ErrURLNotFound) && c.negativeTTL > 0:
+		expires = now.Add(c.negativeTTL)
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+
+	e := &entry{key: key, url: u, err: err, expires: expires, gen: gen}
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *urlRepository) evictIf(match func(*entry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*entry)) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+func (c *urlRepository) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *urlRepository) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+func shortKey(short string) string { return "short:" + short }
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/memory"
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepo counts the reads reaching the repository and, when gate
+// is set, holds them until it is closed.
+type countingRepo struct {
+	service.URLRepository
+	gets atomic.Int32
+	gate chan struct{}
+}
+
+func (r *countingRepo) Get(ctx context.Context, short string) (model.URLStore, error) {
+	r.gets.Add(1)
+	if r.gate != nil {
+		<-r.gate
+	}
+	return r.URLRepository.Get(ctx, short)
+}
+
+func newTestRepo(t *testing.T, size int) (*urlRepository, *countingRepo) {
+	return newNegativeTestRepo(t, size, 0)
+}
+
+func newNegativeTestRepo(t *testing.T, size int, negativeTTL time.Duration) (*urlRepository, *countingRepo) {
+	t.Helper()
+
+	mem, err := memory.NewURLRepository()
+	require.NoError(t, err)
+	inner := &countingRepo{URLRepository: mem}
+
+	return NewURLRepository(inner, size, time.Minute, negativeTTL), inner
+}
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		c, _ := newTestRepo(t, 100)
+		return c
+	})
+}
+
+func TestURLRepositoryNegative(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		c, _ := newNegativeTestRepo(t, 100, time.Minute)
+		return c
+	})
+}
+
+func TestHits(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newTestRepo(t, 100)
+	_, err := c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+
+	for range 3 {
+		u, err := c.Get(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", u.Original)
+	}
+
+	assert.EqualValues(t, 1, inner.gets.Load())
+	hits, misses := c.Counts()
+	assert.EqualValues(t, 2, hits)
+	assert.EqualValues(t, 1, misses)
+}
+
+func TestMissesNotCached(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newTestRepo(t, 100)
+
+	for range 2 {
+		_, err := c.Get(ctx, "abc")
+		require.ErrorIs(t, err, model.ErrURLNotFound)
+	}
+	assert.EqualValues(t, 2, inner.gets.Load())
+	assert.Zero(t, c.order.Len())
+
+	// A link saved around the cache, as by another replica, resolves
+	// at once.
+	_, err := inner.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+}
+
+func TestNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newNegativeTestRepo(t, 100, time.Second)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for range 2 {
+		_, err := c.Get(ctx, "missing")
+		require.ErrorIs(t, err, model.ErrURLNotFound)
+	}
+	assert.EqualValues(t, 1, inner.gets.Load(), "the second miss is served from the cache")
+
+	// A link saved around the cache, as by another replica, is hidden
+	// until the negative TTL runs out.
+	_, err := inner.Save(ctx, model.URLStore{UserID: "u1", Short: "missing", Original: "https://example.com/m"})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "missing")
+	require.ErrorIs(t, err, model.ErrURLNotFound)
+
+	c.now = func() time.Time { return now.Add(2 * time.Second) }
+	_, err = c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, inner.gets.Load())
+
+	// Saving through the cache evicts a cached miss at once.
+	_, err = c.Get(ctx, "abc")
+	require.ErrorIs(t, err, model.ErrURLNotFound)
+	_, err = c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+
+	_, err = c.Get(ctx, "def")
+	require.ErrorIs(t, err, model.ErrURLNotFound)
+	require.NoError(t, c.SaveAll(ctx, []model.URLStore{{UserID: "u1", Short: "def", Original: "https://example.com/d"}}))
+	_, err = c.Get(ctx, "def")
+	require.NoError(t, err)
+}
+
+func TestDeleteBatchInvalidates(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestRepo(t, 100)
+	_, err := c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+
+	require.NoError(t, c.DeleteBatch(ctx, "u1", []string{"abc"}))
+
+	_, err = c.Get(ctx, "abc")
+	assert.ErrorIs(t, err, model.ErrDeleted)
+}
+
+func TestGetByIDAfterWrite(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestRepo(t, 100)
+	_, err := c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	u, err := c.Get(ctx, "abc")
+	require.NoError(t, err)
+
+	_, err = c.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	require.NoError(t, c.DeleteBatch(ctx, "u1", []string{"abc"}))
+
+	_, err = c.GetByID(ctx, u.UUID)
+	assert.ErrorIs(t, err, model.ErrDeleted)
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newTestRepo(t, 100)
+	_, err := c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	_, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+	c.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 2, inner.gets.Load())
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newTestRepo(t, 2)
+	require.NoError(t, c.SaveAll(ctx, []model.URLStore{
+		{UserID: "u1", Short: "a", Original: "https://example.com/a"},
+		{UserID: "u1", Short: "b", Original: "https://example.com/b"},
+		{UserID: "u1", Short: "c", Original: "https://example.com/c"},
+	}))
+
+	for _, short := range []string{"a", "b", "a", "c", "a", "b"} {
+		_, err := c.Get(ctx, short)
+		require.NoError(t, err)
+	}
+
+	// c pushed out b, the least recently used, but not a.
+	assert.EqualValues(t, 4, inner.gets.Load())
+	assert.Equal(t, 2, c.order.Len())
+}
+
+func TestCoalescing(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newTestRepo(t, 100)
+	_, err := c.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	inner.gate = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := c.Get(ctx, "abc")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://example.com", u.Original)
+		}()
+	}
+	require.Eventually(t, func() bool {
+		_, misses := c.Counts()
+		return misses == 10
+	}, time.Second, time.Millisecond)
+	close(inner.gate)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, inner.gets.Load())
+}
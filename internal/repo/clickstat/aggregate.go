@@ -0,0 +1,93 @@
+// Package clickstat computes link statistics in memory for the
+// repositories that cannot aggregate clicks where they store them.
+package clickstat
+
+import (
+	"cmp"
+	"slices"
+
+	"shortener/internal/model"
+)
+
+// Aggregate builds the statistics of short from clicks. The series is
+// sparse: buckets without clicks are left out.
+func Aggregate(clicks []model.Click, short string, f model.StatsFilter) model.LinkStats {
+	var (
+		res      = model.LinkStats{Short: short, From: f.From, To: f.To, Bucket: f.Bucket}
+		visitors = make(map[string]struct{})
+		buckets  = make(map[int64]*bucket)
+		refs     = make(map[string]int)
+		agents   = make(map[string]int)
+		places   = make(map[string]int)
+	)
+
+	for _, c := range clicks {
+		if c.Short != short || c.Time.Before(f.From) || !c.Time.Before(f.To) {
+			continue
+		}
+
+		res.TotalClicks++
+		if c.IPHash != "" {
+			visitors[c.IPHash] = struct{}{}
+		}
+
+		start := f.BucketStart(c.Time)
+		b, ok := buckets[start.Unix()]
+		if !ok {
+			b = &bucket{point: model.StatsPoint{Time: start}, visitors: make(map[string]struct{})}
+			buckets[start.Unix()] = b
+		}
+		b.point.Clicks++
+		if c.IPHash != "" {
+			b.visitors[c.IPHash] = struct{}{}
+		}
+
+		count(refs, c.Referrer)
+		count(agents, c.UserAgent)
+		count(places, c.Country)
+	}
+
+	res.UniqueVisitors = len(visitors)
+	res.Series = make([]model.StatsPoint, 0, len(buckets))
+	for _, b := range buckets {
+		b.point.UniqueVisitors = len(b.visitors)
+		res.Series = append(res.Series, b.point)
+	}
+	slices.SortFunc(res.Series, func(a, b model.StatsPoint) int { return a.Time.Compare(b.Time) })
+
+	res.TopReferrers = Top(refs, f.Top)
+	res.TopUserAgents = Top(agents, f.Top)
+	res.TopCountries = Top(places, f.Top)
+
+	return res
+}
+
+type bucket struct {
+	point    model.StatsPoint
+	visitors map[string]struct{}
+}
+
+func count(m map[string]int, v string) {
+	if v != "" {
+		m[v]++
+	}
+}
+
+// Top returns the n most frequent values, ties broken alphabetically.
+func Top(m map[string]int, n int) []model.StatsEntry {
+	res := make([]model.StatsEntry, 0, len(m))
+	for v, c := range m {
+		res = append(res, model.StatsEntry{Value: v, Count: c})
+	}
+	slices.SortFunc(res, func(a, b model.StatsEntry) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
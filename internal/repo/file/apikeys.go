@@ -0,0 +1,179 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"shortener/internal/model"
+)
+
+// apiKeyRecord is a line of the API key log. Each line holds the whole
+// key, replacing earlier lines with the same ID; Deleted removes it.
+type apiKeyRecord struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix,omitempty"`
+	Hash       string     `json:"hash,omitempty"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Deleted    bool       `json:"is_deleted,omitempty"`
+}
+
+func newAPIKeyRecord(k model.APIKey) apiKeyRecord {
+	return apiKeyRecord{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Hash:       k.Hash,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+	}
+}
+
+func (r apiKeyRecord) key() model.APIKey {
+	return model.APIKey{
+		ID:         r.ID,
+		UserID:     r.UserID,
+		Name:       r.Name,
+		Prefix:     r.Prefix,
+		Hash:       r.Hash,
+		Scopes:     r.Scopes,
+		CreatedAt:  r.CreatedAt,
+		ExpiresAt:  r.ExpiresAt,
+		LastUsedAt: r.LastUsedAt,
+	}
+}
+
+func (repo *urlRepository) loadAPIKey(line []byte) error {
+	var r apiKeyRecord
+	if err := json.Unmarshal(line, &r); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+
+	if old, ok := repo.apiKeys[r.ID]; ok {
+		delete(repo.byKeyHash, old.Hash)
+		delete(repo.apiKeys, r.ID)
+		repo.keysStale++
+	}
+	if r.Deleted {
+		repo.keysStale++
+	} else {
+		repo.apiKeys[r.ID] = r.key()
+		repo.byKeyHash[r.Hash] = r.ID
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	if err := repo.keysLog.append(newAPIKeyRecord(k)); err != nil {
+		return fmt.Errorf("file.CreateAPIKey error: %w", err)
+	}
+	repo.apiKeys[k.ID] = k
+	repo.byKeyHash[k.Hash] = k.ID
+
+	return nil
+}
+
+func (repo *urlRepository) APIKeysByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	repo.keysMu.RLock()
+	defer repo.keysMu.RUnlock()
+
+	res := make([]model.APIKey, 0)
+	for _, k := range repo.apiKeys {
+		if k.UserID == userID {
+			res = append(res, k)
+		}
+	}
+	slices.SortFunc(res, func(a, b model.APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return res, nil
+}
+
+func (repo *urlRepository) APIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	repo.keysMu.RLock()
+	defer repo.keysMu.RUnlock()
+
+	id, ok := repo.byKeyHash[hash]
+	if !ok {
+		return model.APIKey{}, model.ErrAPIKeyNotFound
+	}
+
+	return repo.apiKeys[id], nil
+}
+
+func (repo *urlRepository) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	k, ok := repo.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return model.ErrAPIKeyNotFound
+	}
+	if err := repo.keysLog.append(apiKeyRecord{ID: id, Deleted: true}); err != nil {
+		return fmt.Errorf("file.DeleteAPIKey error: %w", err)
+	}
+	delete(repo.apiKeys, id)
+	delete(repo.byKeyHash, k.Hash)
+	repo.keysStale += 2
+
+	return nil
+}
+
+func (repo *urlRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	k, ok := repo.apiKeys[id]
+	if !ok {
+		return model.ErrAPIKeyNotFound
+	}
+	k.LastUsedAt = &at
+	if err := repo.keysLog.append(newAPIKeyRecord(k)); err != nil {
+		return fmt.Errorf("file.TouchAPIKey error: %w", err)
+	}
+	repo.apiKeys[id] = k
+	repo.keysStale++
+
+	return nil
+}
+
+// compactKeys rewrites the API key log with one line per live key,
+// dropping the lines earlier touches and deletions left behind.
+func (repo *urlRepository) compactKeys() error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	if repo.keysStale == 0 {
+		return nil
+	}
+
+	keys := make([]model.APIKey, 0, len(repo.apiKeys))
+	for _, k := range repo.apiKeys {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b model.APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i] = newAPIKeyRecord(k)
+	}
+	if err := repo.keysLog.rewrite(values...); err != nil {
+		return err
+	}
+	repo.keysStale = 0
+
+	return nil
+}
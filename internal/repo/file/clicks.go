@@ -0,0 +1,116 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/clickstat"
+)
+
+// clickLine is a line of the clicks journal: a click, or with Purged
+// set a marker dropping the earlier clicks of its short code.
+type clickLine struct {
+	model.Click
+	Purged bool `json:"is_purged,omitempty"`
+}
+
+func (repo *urlRepository) loadClicks(line []byte) error {
+	var c clickLine
+	if err := json.Unmarshal(line, &c); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+	if c.Purged {
+		repo.dropClicks(c.Short)
+		return nil
+	}
+	repo.clicks = append(repo.clicks, c.Click)
+
+	return nil
+}
+
+func (repo *urlRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	values := make([]any, len(clicks))
+	for i := range clicks {
+		values[i] = clicks[i]
+	}
+
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	if err := repo.clicksLog.append(values...); err != nil {
+		return fmt.Errorf("file.SaveClicks error: %w", err)
+	}
+	repo.clicks = append(repo.clicks, clicks...)
+
+	return nil
+}
+
+func (repo *urlRepository) ClickStats(ctx context.Context, short string, f model.StatsFilter) (model.LinkStats, error) {
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	return clickstat.Aggregate(repo.clicks, short, f), nil
+}
+
+// purgeClicks drops the clicks of purged records, so a reused short code
+// starts with empty statistics. It runs before the records are purged
+// from the URL log: a failure in between only loses the clicks of a link
+// that has already expired.
+func (repo *urlRepository) purgeClicks(recs []record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	values := make([]any, len(recs))
+	for i, r := range recs {
+		values[i] = clickLine{Click: model.Click{Short: r.Short}, Purged: true}
+	}
+
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	if err := repo.clicksLog.append(values...); err != nil {
+		return fmt.Errorf("purge clicks: %w", err)
+	}
+	for _, r := range recs {
+		repo.dropClicks(r.Short)
+	}
+
+	return nil
+}
+
+// dropClicks removes the clicks of short, counting them and the purge
+// marker as stale lines of the journal. It must be called with clicksMu
+// held.
+func (repo *urlRepository) dropClicks(short string) {
+	n := len(repo.clicks)
+	repo.clicks = slices.DeleteFunc(repo.clicks, func(c model.Click) bool {
+		return c.Short == short
+	})
+	repo.clicksStale += n - len(repo.clicks) + 1
+}
+
+// compactClicks rewrites the clicks journal with the clicks still kept,
+// dropping those of purged links and the purge markers.
+func (repo *urlRepository) compactClicks() error {
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	if repo.clicksStale == 0 {
+		return nil
+	}
+
+	values := make([]any, len(repo.clicks))
+	for i := range repo.clicks {
+		values[i] = repo.clicks[i]
+	}
+	if err := repo.clicksLog.rewrite(values...); err != nil {
+		return err
+	}
+	repo.clicksStale = 0
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// seqBlock is how many sequence numbers are reserved per write. The
+// unused part of the last block is skipped after a restart.
+const seqBlock = 100
+
+// seqLine records that the numbers up to Reserved may have been handed
+// out. The journal is rewritten with a single line per block.
+type seqLine struct {
+	Reserved int64 `json:"reserved"`
+}
+
+func (repo *urlRepository) loadSequence(line []byte) error {
+	var l seqLine
+	if err := json.Unmarshal(line, &l); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+	repo.seqReserved = max(repo.seqReserved, l.Reserved)
+	repo.seq = repo.seqReserved
+
+	return nil
+}
+
+func (repo *urlRepository) NextSequence(ctx context.Context) (int64, error) {
+	repo.seqMu.Lock()
+	defer repo.seqMu.Unlock()
+
+	if repo.seq == repo.seqReserved {
+		if err := repo.seqLog.rewrite(seqLine{Reserved: repo.seqReserved + seqBlock}); err != nil {
+			return 0, fmt.Errorf("file.NextSequence error: %w", err)
+		}
+		repo.seqReserved += seqBlock
+	}
+	repo.seq++
+
+	return repo.seq, nil
+}
@@ -8,84 +8,643 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"shortener/internal/model"
+	"shortener/internal/shared/logger"
 )
 
-var nextUUID int = 1
+const compactInterval = 5 * time.Minute
+
+// record is a single line of the storage log. A line with Deleted set
+// is a tombstone for the earlier line with the same short code; a line
+// with Purged set removes that record altogether, one with Reassigned
+// set gives it to UserID, and one with Restored set undoes a deletion.
+type record struct {
+	UUID       int        `json:"uuid"`
+	UserID     string     `json:"user_id"`
+	Short      string     `json:"short_url"`
+	Original   string     `json:"original_url"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Deleted    bool       `json:"is_deleted,omitempty"`
+	Purged     bool       `json:"is_purged,omitempty"`
+	Reassigned bool       `json:"is_reassigned,omitempty"`
+	Restored   bool       `json:"is_restored,omitempty"`
+}
+
+func newRecord(u model.URLStore) record {
+	return record{
+		UUID:      u.UUID,
+		UserID:    u.UserID,
+		Short:     u.Short,
+		Original:  u.Original,
+		ExpiresAt: u.ExpiresAt,
+		Deleted:   u.DeletedFlag,
+	}
+}
+
+func purgeRecord(u model.URLStore) record {
+	return record{UUID: u.UUID, Short: u.Short, Purged: true}
+}
+
+func (r record) store() model.URLStore {
+	return model.URLStore{
+		UUID:        r.UUID,
+		UserID:      r.UserID,
+		Short:       r.Short,
+		Original:    r.Original,
+		ExpiresAt:   r.ExpiresAt,
+		DeletedFlag: r.Deleted,
+	}
+}
 
 type urlRepository struct {
-	mu sync.Mutex
-	db string
+	mu         sync.RWMutex
+	path       string
+	f          *os.File
+	nextUUID   int
+	tombstones int
+	db         map[string]model.URLStore
+	byID       map[int]string
+	byUser     map[string][]string
+	byOrigin   map[string]string
+
+	// stopCompact stops compactLoop, which closes compacted on return.
+	stopCompact context.CancelFunc
+	compacted   chan struct{}
+
+	clicksMu    sync.Mutex
+	clicksLog   *journal
+	clicksStale int
+	clicks      []model.Click
+
+	usersMu  sync.RWMutex
+	usersLog *journal
+	users    map[string]model.User
+	byEmail  map[string]string
+
+	keysMu    sync.RWMutex
+	keysLog   *journal
+	keysStale int
+	apiKeys   map[string]model.APIKey
+	byKeyHash map[string]string
+
+	auditMu  sync.Mutex
+	auditLog *journal
+	audit    []model.AuditEntry
+
+	seqMu       sync.Mutex
+	seqLog      *journal
+	seq         int64
+	seqReserved int64
 }
 
-func NewURLRepository(filePath string) (*urlRepository, error) {
-	return &urlRepository{mu: sync.Mutex{}, db: filePath}, nil
+func NewURLRepository(ctx context.Context, filePath string) (*urlRepository, error) {
+	if dir := filepath.Dir(filePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("file.NewURLRepository error: create dir: %w", err)
+		}
+	}
+
+	repo := &urlRepository{
+		path:      filePath,
+		nextUUID:  1,
+		db:        make(map[string]model.URLStore, 100),
+		byID:      make(map[int]string, 100),
+		byUser:    make(map[string][]string),
+		byOrigin:  make(map[string]string, 100),
+		users:     make(map[string]model.User),
+		byEmail:   make(map[string]string),
+		apiKeys:   make(map[string]model.APIKey),
+		byKeyHash: make(map[string]string),
+	}
+
+	if err := repo.load(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("file.NewURLRepository error: open file: %w", err)
+	}
+	repo.f = f
+
+	sides := []struct {
+		name string
+		j    **journal
+		load func([]byte) error
+	}{
+		{"clicks", &repo.clicksLog, repo.loadClicks},
+		{"users", &repo.usersLog, repo.loadUser},
+		{"apikeys", &repo.keysLog, repo.loadAPIKey},
+		{"audit", &repo.auditLog, repo.loadAudit},
+		{"sequence", &repo.seqLog, repo.loadSequence},
+	}
+	for _, side := range sides {
+		if *side.j, err = openJournal(sidePath(filePath, side.name), side.load); err != nil {
+			repo.Close()
+			return nil, fmt.Errorf("file.NewURLRepository error: %w", err)
+		}
+	}
+
+	ctx, repo.stopCompact = context.WithCancel(ctx)
+	repo.compacted = make(chan struct{})
+	go repo.compactLoop(ctx)
+
+	return repo, nil
+}
+
+// Close stops the compaction and waits for it before closing the files.
+func (repo *urlRepository) Close() error {
+	if repo.stopCompact != nil {
+		repo.stopCompact()
+		<-repo.compacted
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	errs := []error{repo.f.Close()}
+	for _, j := range []*journal{repo.clicksLog, repo.usersLog, repo.keysLog, repo.auditLog, repo.seqLog} {
+		if j != nil {
+			errs = append(errs, j.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (repo *urlRepository) Ping(ctx context.Context) error {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if _, err := repo.f.Stat(); err != nil {
+		return fmt.Errorf("file.Ping error: %w", err)
+	}
+
+	return nil
 }
 
 func (repo *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
-	u.UUID = nextUUID
 
-	f, err := os.OpenFile(repo.db, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
-	if err != nil {
-		return "", fmt.Errorf("file.Save error: open file: %w", err)
+	now := time.Now()
+	if short, err := repo.conflict(u, now); err != nil {
+		return short, err
 	}
 
-	if err := json.NewEncoder(f).Encode(u); err != nil {
-		return "", fmt.Errorf("file.Save error: marshal error: %w", err)
+	u.UUID = repo.nextUUID
+	purged := repo.expiredConflicts(u)
+	if err := repo.purgeClicks(purged); err != nil {
+		return "", fmt.Errorf("file.Save error: %w", err)
+	}
+	if err := repo.append(append(purged, newRecord(u))...); err != nil {
+		return "", fmt.Errorf("file.Save error: %w", err)
 	}
-	nextUUID++
+	for _, r := range purged {
+		repo.purge(r.Short)
+	}
+	repo.index(u)
 
 	return u.Short, nil
 }
 
+func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	origins := make(map[string]struct{}, len(urls))
+	shorts := make(map[string]struct{}, len(urls))
+	for _, u := range urls {
+		if _, err := repo.conflict(u, now); err != nil {
+			return err
+		}
+		if _, ok := origins[u.Original]; ok {
+			return model.ErrURLAlreadyExists
+		}
+		if _, ok := shorts[u.Short]; ok {
+			return model.ErrShortExists
+		}
+		origins[u.Original] = struct{}{}
+		shorts[u.Short] = struct{}{}
+	}
+
+	var purged, recs []record
+	for i, u := range urls {
+		u.UUID = repo.nextUUID + i
+		purged = append(purged, repo.expiredConflicts(u)...)
+		recs = append(recs, newRecord(u))
+	}
+	if err := repo.purgeClicks(purged); err != nil {
+		return fmt.Errorf("file.SaveAll error: %w", err)
+	}
+	if err := repo.append(append(purged, recs...)...); err != nil {
+		return fmt.Errorf("file.SaveAll error: %w", err)
+	}
+	for _, r := range purged {
+		repo.purge(r.Short)
+	}
+	for _, r := range recs {
+		repo.index(r.store())
+	}
+
+	return nil
+}
+
 func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
+	repo.mu.RLock()
+	u, ok := repo.db[short]
+	repo.mu.RUnlock()
+	if !ok {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return check(u)
+}
+
+func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	short, ok := repo.byID[uuid]
+	if !ok {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return check(repo.db[short])
+}
+
+func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	now := time.Now()
+	res := make([]model.URLStore, 0, len(repo.byUser[userID]))
+	for _, short := range repo.byUser[userID] {
+		if u := repo.db[short]; !u.DeletedFlag && !u.Expired(now) {
+			res = append(res, u)
+		}
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	recs := make([]record, 0, len(urls))
+	for _, short := range urls {
+		u, ok := repo.db[short]
+		if !ok || u.UserID != userID || u.DeletedFlag {
+			continue
+		}
+		u.DeletedFlag = true
+		recs = append(recs, newRecord(u))
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+
+	if err := repo.append(recs...); err != nil {
+		return fmt.Errorf("file.DeleteBatch error: %w", err)
+	}
+	for _, r := range recs {
+		repo.db[r.Short] = r.store()
+	}
+	repo.tombstones += len(recs)
+
+	return nil
+}
+
+func (repo *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var recs []record
+	for _, u := range repo.db {
+		if u.Expired(before) {
+			recs = append(recs, purgeRecord(u))
+		}
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	if err := repo.purgeClicks(recs); err != nil {
+		return 0, fmt.Errorf("file.DeleteExpired error: %w", err)
+	}
+	if err := repo.append(recs...); err != nil {
+		return 0, fmt.Errorf("file.DeleteExpired error: %w", err)
+	}
+	for _, r := range recs {
+		repo.purge(r.Short)
+	}
+	repo.tombstones += len(recs)
+
+	return len(recs), nil
+}
+
+func (repo *urlRepository) Stats(ctx context.Context) (model.ServiceStats, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	now := time.Now()
+	res := model.ServiceStats{URLs: len(repo.db)}
+	for _, u := range repo.db {
+		switch {
+		case u.DeletedFlag:
+			res.Deleted++
+		case u.Expired(now):
+			res.Expired++
+		default:
+			res.Live++
+		}
+	}
+	for _, shorts := range repo.byUser {
+		if len(shorts) > 0 {
+			res.Users++
+		}
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
-	f, err := os.OpenFile(repo.db, os.O_RDONLY|os.O_CREATE, 0666)
+
+	shorts := slices.Clone(repo.byUser[from])
+	if len(shorts) == 0 {
+		return 0, nil
+	}
+
+	recs := make([]record, 0, len(shorts))
+	for _, short := range shorts {
+		r := newRecord(repo.db[short])
+		r.UserID, r.Reassigned = to, true
+		recs = append(recs, r)
+	}
+	if err := repo.append(recs...); err != nil {
+		return 0, fmt.Errorf("file.ReassignUser error: %w", err)
+	}
+	for _, short := range shorts {
+		repo.reassign(short, to)
+	}
+	repo.tombstones += len(recs)
+
+	return len(recs), nil
+}
+
+func check(u model.URLStore) (model.URLStore, error) {
+	if u.DeletedFlag {
+		return model.URLStore{}, model.ErrDeleted
+	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
+
+	return u, nil
+}
+
+// conflict reports why u cannot be stored. Expired records that have
+// not been reaped yet do not count: they are purged on insert.
+func (repo *urlRepository) conflict(u model.URLStore, now time.Time) (string, error) {
+	if short, ok := repo.byOrigin[u.Original]; ok && !repo.db[short].Expired(now) {
+		return short, model.ErrURLAlreadyExists
+	}
+	if old, ok := repo.db[u.Short]; ok && !old.Expired(now) {
+		return "", model.ErrShortExists
+	}
+
+	return "", nil
+}
+
+// expiredConflicts returns purge records for the expired records that u
+// replaces. They have to precede u in the log, otherwise a reload would
+// take u for a tombstone of the record it replaced.
+func (repo *urlRepository) expiredConflicts(u model.URLStore) []record {
+	var recs []record
+	if short, ok := repo.byOrigin[u.Original]; ok {
+		recs = append(recs, purgeRecord(repo.db[short]))
+	}
+	if old, ok := repo.db[u.Short]; ok && old.Original != u.Original {
+		recs = append(recs, purgeRecord(old))
+	}
+
+	return recs
+}
+
+// load replays the log into the indexes. A repeated short code is a
+// tombstone for the record first written under it.
+func (repo *urlRepository) load() error {
+	f, err := os.Open(repo.path)
 	if err != nil {
-		return model.URLStore{}, fmt.Errorf("file.Get error: open file error: %w", err)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("file.load error: open file: %w", err)
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if bytes.Contains(line, []byte(short)) {
-			var u model.URLStore
-			if err := json.Unmarshal(line, &u); err != nil {
-				return model.URLStore{}, fmt.Errorf("file.Get error: unmarshal error: %w", err)
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return fmt.Errorf("file.load error: unmarshal error: %w", err)
+		}
+
+		if r.Purged {
+			repo.purge(r.Short)
+			repo.tombstones++
+			continue
+		}
+		if r.Reassigned {
+			repo.reassign(r.Short, r.UserID)
+			repo.tombstones++
+			continue
+		}
+		if r.Restored {
+			if u, ok := repo.db[r.Short]; ok {
+				u.DeletedFlag = false
+				repo.db[r.Short] = u
 			}
-			return u, nil
+			repo.tombstones++
+			continue
+		}
+		if u, ok := repo.db[r.Short]; ok {
+			repo.tombstones++
+			u.DeletedFlag = u.DeletedFlag || r.Deleted
+			repo.db[r.Short] = u
+			continue
+		}
+		if _, ok := repo.byID[r.UUID]; ok {
+			// Logs written by older versions restarted the UUID
+			// sequence on every launch; renumber and let the next
+			// compaction persist the fix.
+			r.UUID = repo.nextUUID
+			repo.tombstones++
 		}
+		repo.index(r.store())
 	}
 
 	if err := scanner.Err(); err != nil {
-		return model.URLStore{}, fmt.Errorf("file.Get error: scanner error: %w", err)
+		return fmt.Errorf("file.load error: scanner error: %w", err)
 	}
 
-	return model.URLStore{}, model.ErrURLNotFound
+	return nil
 }
 
-func (repo *urlRepository) Ping(ctx context.Context) error {
-	return errors.New("unimplemented")
+// index must be called with repo.mu held for writing.
+func (repo *urlRepository) index(u model.URLStore) {
+	if u.UUID >= repo.nextUUID {
+		repo.nextUUID = u.UUID + 1
+	}
+
+	repo.db[u.Short] = u
+	repo.byID[u.UUID] = u.Short
+	repo.byUser[u.UserID] = append(repo.byUser[u.UserID], u.Short)
+	repo.byOrigin[u.Original] = u.Short
 }
 
-func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
-	return errors.New("unimplemented")
+// purge removes a record from every index. It must be called with
+// repo.mu held for writing.
+func (repo *urlRepository) purge(short string) {
+	u, ok := repo.db[short]
+	if !ok {
+		return
+	}
+
+	delete(repo.db, short)
+	delete(repo.byID, u.UUID)
+	delete(repo.byOrigin, u.Original)
+	repo.byUser[u.UserID] = slices.DeleteFunc(repo.byUser[u.UserID], func(s string) bool {
+		return s == short
+	})
 }
 
-func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
-	return []model.URLStore{}, errors.New("unimplemented")
+// reassign gives a record to userID. It must be called with repo.mu
+// held for writing.
+func (repo *urlRepository) reassign(short, userID string) {
+	u, ok := repo.db[short]
+	if !ok || u.UserID == userID {
+		return
+	}
+
+	repo.byUser[u.UserID] = slices.DeleteFunc(repo.byUser[u.UserID], func(s string) bool {
+		return s == short
+	})
+	u.UserID = userID
+	repo.db[short] = u
+	repo.byUser[userID] = append(repo.byUser[userID], short)
 }
 
-func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
-	return errors.New("unimplemented")
+// append writes the records in a single write call, so a batch is
+// either fully in the log or not at all. It must be called with
+// repo.mu held for writing.
+func (repo *urlRepository) append(recs ...record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("marshal error: %w", err)
+		}
+	}
+
+	if _, err := repo.f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+
+	return nil
 }
 
-func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
-	return model.URLStore{}, errors.New("unimplemented")
+func (repo *urlRepository) compactLoop(ctx context.Context) {
+	defer close(repo.compacted)
+
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := repo.compact(); err != nil {
+				logger.L().Error("file.compact", logger.Error(err))
+			}
+			if err := repo.compactKeys(); err != nil {
+				logger.L().Error("file.compactKeys", logger.Error(err))
+			}
+			if err := repo.compactClicks(); err != nil {
+				logger.L().Error("file.compactClicks", logger.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// compact rewrites the log with exactly one line per record, folding
+// tombstones into the records they mark as deleted and dropping purged
+// records.
+func (repo *urlRepository) compact() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.tombstones == 0 {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(repo.path), filepath.Base(repo.path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for id := 1; id < repo.nextUUID; id++ {
+		short, ok := repo.byID[id]
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(newRecord(repo.db[short])); err != nil {
+			tmp.Close()
+			return fmt.Errorf("marshal error: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), repo.path); err != nil {
+		return fmt.Errorf("replace log: %w", err)
+	}
+
+	f, err := os.OpenFile(repo.path, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("reopen log: %w", err)
+	}
+	repo.f.Close()
+	repo.f = f
+	repo.tombstones = 0
+
+	return nil
 }
@@ -0,0 +1,304 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRepo(t *testing.T, path string) *urlRepository {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo, err := NewURLRepository(ctx, path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cancel()
+		repo.Close()
+	})
+	return repo
+}
+
+func TestCloseStopsCompaction(t *testing.T) {
+	repo, err := NewURLRepository(context.Background(), filepath.Join(t.TempDir(), "db.json"))
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Close())
+	select {
+	case <-repo.compacted:
+	default:
+		t.Fatal("compactLoop is still running after Close")
+	}
+}
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestClicks(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repotest.ClickStore {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestUsers(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) repotest.UserStore {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	repotest.RunAPIKeys(t, func(t *testing.T) service.APIKeyRepository {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	repotest.RunAdmin(t, func(t *testing.T) repotest.AdminStore {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestSequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) service.SequenceRepository {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "db.json"))
+	})
+}
+
+func TestSequenceReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	repo := newTestRepo(t, path)
+	for range seqBlock + 1 {
+		_, err := repo.NextSequence(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	// The rest of the second block is skipped, never handed out again.
+	n, err := newTestRepo(t, path).NextSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*seqBlock+1), n)
+}
+
+func TestURLRepositoryReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	repo := newTestRepo(t, path)
+	_, err := repo.Save(ctx, model.URLStore{UserID: "u1", Short: "one", Original: "https://example.com/1"})
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		{UserID: "u1", Short: "two", Original: "https://example.com/2"},
+		{UserID: "u2", Short: "three", Original: "https://example.com/3"},
+	}))
+	require.NoError(t, repo.DeleteBatch(ctx, "u1", []string{"two"}))
+	_, err = repo.ReassignUser(ctx, "u2", "u4")
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "old", Original: "https://example.com/old", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u3", Short: "old", Original: "https://example.com/new"})
+	require.NoError(t, err)
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			require.NoError(t, repo.compact())
+		}
+
+		reloaded := newTestRepo(t, path)
+
+		_, err = reloaded.Get(ctx, "two")
+		assert.ErrorIs(t, err, model.ErrDeleted)
+
+		u, err := reloaded.Get(ctx, "three")
+		require.NoError(t, err)
+		assert.Equal(t, 3, u.UUID)
+		assert.Equal(t, "u4", u.UserID)
+
+		urls, err := reloaded.GetAllByUser(ctx, "u2")
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+
+		_, err = reloaded.Save(ctx, model.URLStore{UserID: "u1", Short: "one", Original: "https://example.com/1"})
+		assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+
+		u, err = reloaded.Get(ctx, "old")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", u.Original)
+
+		assert.Equal(t, 6, reloaded.nextUUID)
+	}
+}
+
+func TestClicksReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	day := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	repo := newTestRepo(t, path)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+		{Time: day.Add(time.Hour), Short: "abc", IPHash: "ip1"},
+		{Time: day.Add(2 * time.Hour), Short: "abc", IPHash: "ip2"},
+	}))
+
+	// Clicks of a purged link stay dropped; those of the link reusing
+	// its short code are kept.
+	soon := time.Now().Add(time.Minute)
+	_, err := repo.Save(ctx, model.URLStore{UserID: "u1", Short: "gone", Original: "https://example.com/gone", ExpiresAt: &soon})
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{{Time: day, Short: "gone", IPHash: "ip1"}}))
+	_, err = repo.DeleteExpired(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u2", Short: "gone", Original: "https://example.com/new"})
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{{Time: day, Short: "gone", IPHash: "ip2"}}))
+
+	f := model.StatsFilter{
+		From:   day,
+		To:     day.Add(24 * time.Hour),
+		Bucket: model.BucketDay,
+		Top:    10,
+	}
+	for _, compact := range []bool{false, true} {
+		if compact {
+			// The purged click and its marker are gone from the journal.
+			require.NoError(t, repo.compactClicks())
+			b, err := os.ReadFile(sidePath(path, "clicks"))
+			require.NoError(t, err)
+			assert.Equal(t, 3, bytes.Count(b, []byte("\n")))
+			assert.Zero(t, repo.clicksStale)
+		}
+
+		reloaded := newTestRepo(t, path)
+		if !compact {
+			// A restart still compacts what was purged before it.
+			assert.Equal(t, 2, reloaded.clicksStale)
+		}
+		res, err := reloaded.ClickStats(ctx, "abc", f)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.TotalClicks)
+		assert.Equal(t, 2, res.UniqueVisitors)
+
+		res, err = reloaded.ClickStats(ctx, "gone", f)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.TotalClicks)
+	}
+
+	// Clicks saved after compaction land in the new file.
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{{Time: day, Short: "abc", IPHash: "ip3"}}))
+	res, err := newTestRepo(t, path).ClickStats(ctx, "abc", f)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.TotalClicks)
+}
+
+func TestUsersReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	repo := newTestRepo(t, path)
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "u1", Email: "ann@example.com", PasswordHash: "hash", CreatedAt: time.Now()}))
+
+	reloaded := newTestRepo(t, path)
+	u, err := reloaded.UserByEmail(ctx, "ann@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "u1", u.ID)
+
+	err = reloaded.CreateUser(ctx, model.User{ID: "u2", Email: "ann@example.com"})
+	assert.ErrorIs(t, err, model.ErrUserExists)
+}
+
+func TestAPIKeysReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	repo := newTestRepo(t, path)
+	for _, id := range []string{"k1", "k2"} {
+		require.NoError(t, repo.CreateAPIKey(ctx, model.APIKey{ID: id, UserID: "u1", Hash: "hash-" + id, Scopes: []string{model.ScopeRead}, CreatedAt: now}))
+	}
+	require.NoError(t, repo.TouchAPIKey(ctx, "k1", now.Add(time.Minute)))
+	require.NoError(t, repo.DeleteAPIKey(ctx, "u1", "k2"))
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			require.NoError(t, repo.compactKeys())
+			b, err := os.ReadFile(sidePath(path, "apikeys"))
+			require.NoError(t, err)
+			assert.Equal(t, 1, bytes.Count(b, []byte("\n")))
+			assert.Zero(t, repo.keysStale)
+		}
+
+		reloaded := newTestRepo(t, path)
+		keys, err := reloaded.APIKeysByUser(ctx, "u1")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.NotNil(t, keys[0].LastUsedAt)
+		assert.True(t, now.Add(time.Minute).Equal(*keys[0].LastUsedAt))
+
+		_, err = reloaded.APIKeyByHash(ctx, "hash-k2")
+		assert.ErrorIs(t, err, model.ErrAPIKeyNotFound)
+	}
+
+	// Keys written after compaction land in the new file.
+	require.NoError(t, repo.TouchAPIKey(ctx, "k1", now.Add(time.Hour)))
+	keys, err := newTestRepo(t, path).APIKeysByUser(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, now.Add(time.Hour).Equal(*keys[0].LastUsedAt))
+}
+
+func TestAdminReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	repo := newTestRepo(t, path)
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		{UserID: "u1", Short: "one", Original: "https://example.com/1"},
+		{UserID: "u1", Short: "two", Original: "https://example.com/2"},
+	}))
+	require.NoError(t, repo.SetDeleted(ctx, "one", true))
+	require.NoError(t, repo.SetDeleted(ctx, "one", false))
+	require.NoError(t, repo.SetDeleted(ctx, "two", true))
+	require.NoError(t, repo.ReassignURL(ctx, "two", "u2"))
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "a1", Email: "admin@example.com", CreatedAt: time.Now()}))
+	require.NoError(t, repo.SetRole(ctx, "a1", model.RoleAdmin))
+	require.NoError(t, repo.SaveAudit(ctx, model.AuditEntry{Actor: "a1", Action: model.AuditDisable, Target: "two", At: time.Now()}))
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			require.NoError(t, repo.compact())
+		}
+
+		reloaded := newTestRepo(t, path)
+
+		_, err := reloaded.Get(ctx, "one")
+		require.NoError(t, err, "restored")
+
+		u, err := reloaded.FindURL(ctx, "two", "")
+		require.NoError(t, err)
+		assert.True(t, u.DeletedFlag)
+		assert.Equal(t, "u2", u.UserID)
+
+		admin, err := reloaded.UserByID(ctx, "a1")
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, admin.Role)
+
+		entries, err := reloaded.AuditLog(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, int64(1), entries[0].ID)
+	}
+}
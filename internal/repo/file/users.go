@@ -0,0 +1,77 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) loadUser(line []byte) error {
+	var u model.User
+	if err := json.Unmarshal(line, &u); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+	repo.users[u.ID] = u
+	repo.byEmail[u.Email] = u.ID
+
+	return nil
+}
+
+func (repo *urlRepository) CreateUser(ctx context.Context, u model.User) error {
+	repo.usersMu.Lock()
+	defer repo.usersMu.Unlock()
+
+	if _, ok := repo.byEmail[u.Email]; ok {
+		return model.ErrUserExists
+	}
+	if err := repo.usersLog.append(u); err != nil {
+		return fmt.Errorf("file.CreateUser error: %w", err)
+	}
+	repo.users[u.ID] = u
+	repo.byEmail[u.Email] = u.ID
+
+	return nil
+}
+
+func (repo *urlRepository) UserByEmail(ctx context.Context, email string) (model.User, error) {
+	repo.usersMu.RLock()
+	defer repo.usersMu.RUnlock()
+
+	id, ok := repo.byEmail[email]
+	if !ok {
+		return model.User{}, model.ErrUserNotFound
+	}
+
+	return repo.users[id], nil
+}
+
+func (repo *urlRepository) UserByID(ctx context.Context, id string) (model.User, error) {
+	repo.usersMu.RLock()
+	defer repo.usersMu.RUnlock()
+
+	u, ok := repo.users[id]
+	if !ok {
+		return model.User{}, model.ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) SetRole(ctx context.Context, userID, role string) error {
+	repo.usersMu.Lock()
+	defer repo.usersMu.Unlock()
+
+	u, ok := repo.users[userID]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	u.Role = role
+	if err := repo.usersLog.append(u); err != nil {
+		return fmt.Errorf("file.SetRole error: %w", err)
+	}
+	repo.users[userID] = u
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) FindURL(ctx context.Context, short, original string) (model.URLStore, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if short == "" {
+		short = repo.byOrigin[original]
+	}
+	u, ok := repo.db[short]
+	if !ok {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) URLsByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	res := make([]model.URLStore, 0, len(repo.byUser[userID]))
+	for _, short := range repo.byUser[userID] {
+		res = append(res, repo.db[short])
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) SetDeleted(ctx context.Context, short string, deleted bool) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	u, ok := repo.db[short]
+	if !ok {
+		return model.ErrURLNotFound
+	}
+	u.DeletedFlag = deleted
+	repo.db[short] = u
+
+	return nil
+}
+
+func (repo *urlRepository) ReassignURL(ctx context.Context, short, to string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	u, ok := repo.db[short]
+	if !ok {
+		return model.ErrURLNotFound
+	}
+	if u.UserID == to {
+		return nil
+	}
+
+	repo.byUser[u.UserID] = slices.DeleteFunc(repo.byUser[u.UserID], func(s string) bool {
+		return s == short
+	})
+	u.UserID = to
+	repo.db[short] = u
+	repo.byUser[to] = append(repo.byUser[to], short)
+
+	return nil
+}
+
+func (repo *urlRepository) SaveAudit(ctx context.Context, e model.AuditEntry) error {
+	repo.auditMu.Lock()
+	defer repo.auditMu.Unlock()
+
+	e.ID = int64(len(repo.audit) + 1)
+	repo.audit = append(repo.audit, e)
+
+	return nil
+}
+
+func (repo *urlRepository) AuditLog(ctx context.Context, limit int) ([]model.AuditEntry, error) {
+	repo.auditMu.Lock()
+	defer repo.auditMu.Unlock()
+
+	res := make([]model.AuditEntry, 0, min(limit, len(repo.audit)))
+	for i := len(repo.audit) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, repo.audit[i])
+	}
+
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	repo.apiKeys[k.ID] = k
+	repo.byKeyHash[k.Hash] = k.ID
+
+	return nil
+}
+
+func (repo *urlRepository) APIKeysByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	repo.keysMu.RLock()
+	defer repo.keysMu.RUnlock()
+
+	res := make([]model.APIKey, 0)
+	for _, k := range repo.apiKeys {
+		if k.UserID == userID {
+			res = append(res, k)
+		}
+	}
+	slices.SortFunc(res, func(a, b model.APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return res, nil
+}
+
+func (repo *urlRepository) APIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	repo.keysMu.RLock()
+	defer repo.keysMu.RUnlock()
+
+	id, ok := repo.byKeyHash[hash]
+	if !ok {
+		return model.APIKey{}, model.ErrAPIKeyNotFound
+	}
+
+	return repo.apiKeys[id], nil
+}
+
+func (repo *urlRepository) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	k, ok := repo.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return model.ErrAPIKeyNotFound
+	}
+	delete(repo.apiKeys, id)
+	delete(repo.byKeyHash, k.Hash)
+
+	return nil
+}
+
+func (repo *urlRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	repo.keysMu.Lock()
+	defer repo.keysMu.Unlock()
+
+	k, ok := repo.apiKeys[id]
+	if !ok {
+		return model.ErrAPIKeyNotFound
+	}
+	k.LastUsedAt = &at
+	repo.apiKeys[id] = k
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"context"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/clickstat"
+)
+
+func (repo *urlRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	repo.clicks = append(repo.clicks, clicks...)
+
+	return nil
+}
+
+func (repo *urlRepository) ClickStats(ctx context.Context, short string, f model.StatsFilter) (model.LinkStats, error) {
+	repo.clicksMu.Lock()
+	defer repo.clicksMu.Unlock()
+
+	return clickstat.Aggregate(repo.clicks, short, f), nil
+}
@@ -0,0 +1,7 @@
+package memory
+
+import "context"
+
+func (repo *urlRepository) NextSequence(ctx context.Context) (int64, error) {
+	return repo.seq.Add(1), nil
+}
@@ -2,72 +2,271 @@ package memory
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"shortener/internal/model"
 )
 
-var nextUUID int = 1
-
 type urlRepository struct {
-	mu sync.Mutex
-	db map[string][]byte
+	mu       sync.RWMutex
+	nextUUID int
+	db       map[string]model.URLStore
+	byID     map[int]string
+	byUser   map[string][]string
+	byOrigin map[string]string
+
+	clicksMu sync.Mutex
+	clicks   []model.Click
+
+	usersMu sync.RWMutex
+	users   map[string]model.User
+	byEmail map[string]string
+
+	keysMu    sync.RWMutex
+	apiKeys   map[string]model.APIKey
+	byKeyHash map[string]string
+
+	auditMu sync.Mutex
+	audit   []model.AuditEntry
+
+	seq atomic.Int64
 }
 
 func NewURLRepository() (*urlRepository, error) {
-	return &urlRepository{mu: sync.Mutex{}, db: make(map[string][]byte, 100)}, nil
+	return &urlRepository{
+		nextUUID:  1,
+		db:        make(map[string]model.URLStore, 100),
+		byID:      make(map[int]string, 100),
+		byUser:    make(map[string][]string),
+		byOrigin:  make(map[string]string, 100),
+		users:     make(map[string]model.User),
+		byEmail:   make(map[string]string),
+		apiKeys:   make(map[string]model.APIKey),
+		byKeyHash: make(map[string]string),
+	}, nil
 }
 
+func (repo *urlRepository) Ping(ctx context.Context) error { return nil }
+
+func (repo *urlRepository) Close() error { return nil }
+
 func (repo *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
-	u.UUID = nextUUID
-	b, err := json.Marshal(u)
-	if err != nil {
-		return "", fmt.Errorf("memory.Save error: marshal error: %w", err)
+
+	if short, err := repo.conflict(u, time.Now()); err != nil {
+		return short, err
 	}
 
-	repo.db[u.Short] = b
-	nextUUID++
+	repo.insert(u)
 
 	return u.Short, nil
 }
 
-func (repo *urlRepository) Get(ctx context.Context, key string) (model.URLStore, error) {
+func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
 	repo.mu.Lock()
-	val, ok := repo.db[key]
-	repo.mu.Unlock()
-	if !ok {
-		return model.URLStore{}, model.ErrURLNotFound
+	defer repo.mu.Unlock()
+
+	// The batch is validated as a whole before anything is written,
+	// so a conflict leaves the store untouched.
+	now := time.Now()
+	origins := make(map[string]struct{}, len(urls))
+	shorts := make(map[string]struct{}, len(urls))
+	for _, u := range urls {
+		if _, err := repo.conflict(u, now); err != nil {
+			return err
+		}
+		if _, ok := origins[u.Original]; ok {
+			return model.ErrURLAlreadyExists
+		}
+		if _, ok := shorts[u.Short]; ok {
+			return model.ErrShortExists
+		}
+		origins[u.Original] = struct{}{}
+		shorts[u.Short] = struct{}{}
 	}
 
-	var u model.URLStore
-	if err := json.Unmarshal(val, &u); err != nil {
-		return model.URLStore{}, fmt.Errorf("memory.Save error: unmarshal error: %w", err)
+	for _, u := range urls {
+		repo.insert(u)
 	}
 
-	return u, nil
+	return nil
 }
 
-func (repo *urlRepository) Ping(ctx context.Context) error {
-	return errors.New("unimplemented")
+func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
+	repo.mu.RLock()
+	u, ok := repo.db[short]
+	repo.mu.RUnlock()
+	if !ok {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return check(u)
 }
 
-func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
-	return errors.New("unimplemented")
+func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	short, ok := repo.byID[uuid]
+	if !ok {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return check(repo.db[short])
 }
 
 func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
-	return []model.URLStore{}, errors.New("unimplemented")
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	now := time.Now()
+	res := make([]model.URLStore, 0, len(repo.byUser[userID]))
+	for _, short := range repo.byUser[userID] {
+		if u := repo.db[short]; !u.DeletedFlag && !u.Expired(now) {
+			res = append(res, u)
+		}
+	}
+
+	return res, nil
 }
 
 func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
-	return errors.New("unimplemented")
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, short := range urls {
+		u, ok := repo.db[short]
+		if !ok || u.UserID != userID {
+			continue
+		}
+		u.DeletedFlag = true
+		repo.db[short] = u
+	}
+
+	return nil
 }
 
-func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
-	return model.URLStore{}, errors.New("unimplemented")
+func (repo *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var n int
+	for short, u := range repo.db {
+		if u.Expired(before) {
+			repo.purge(short)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (repo *urlRepository) Stats(ctx context.Context) (model.ServiceStats, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	now := time.Now()
+	res := model.ServiceStats{URLs: len(repo.db)}
+	for _, u := range repo.db {
+		switch {
+		case u.DeletedFlag:
+			res.Deleted++
+		case u.Expired(now):
+			res.Expired++
+		default:
+			res.Live++
+		}
+	}
+	for _, shorts := range repo.byUser {
+		if len(shorts) > 0 {
+			res.Users++
+		}
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	moved := repo.byUser[from]
+	for _, short := range moved {
+		u := repo.db[short]
+		u.UserID = to
+		repo.db[short] = u
+	}
+	delete(repo.byUser, from)
+	repo.byUser[to] = append(repo.byUser[to], moved...)
+
+	return len(moved), nil
+}
+
+func check(u model.URLStore) (model.URLStore, error) {
+	if u.DeletedFlag {
+		return model.URLStore{}, model.ErrDeleted
+	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
+
+	return u, nil
+}
+
+// conflict reports why u cannot be stored. Expired records that have
+// not been reaped yet do not count: insert purges them.
+func (repo *urlRepository) conflict(u model.URLStore, now time.Time) (string, error) {
+	if short, ok := repo.byOrigin[u.Original]; ok && !repo.db[short].Expired(now) {
+		return short, model.ErrURLAlreadyExists
+	}
+	if old, ok := repo.db[u.Short]; ok && !old.Expired(now) {
+		return "", model.ErrShortExists
+	}
+
+	return "", nil
+}
+
+// insert must be called with repo.mu held for writing.
+func (repo *urlRepository) insert(u model.URLStore) {
+	if short, ok := repo.byOrigin[u.Original]; ok {
+		repo.purge(short)
+	}
+	if _, ok := repo.db[u.Short]; ok {
+		repo.purge(u.Short)
+	}
+
+	u.UUID = repo.nextUUID
+	repo.nextUUID++
+
+	repo.db[u.Short] = u
+	repo.byID[u.UUID] = u.Short
+	repo.byUser[u.UserID] = append(repo.byUser[u.UserID], u.Short)
+	repo.byOrigin[u.Original] = u.Short
+}
+
+// purge removes a record from every index, together with its clicks,
+// so a reused short code starts with empty statistics. It must be
+// called with repo.mu held for writing.
+func (repo *urlRepository) purge(short string) {
+	u, ok := repo.db[short]
+	if !ok {
+		return
+	}
+
+	delete(repo.db, short)
+	delete(repo.byID, u.UUID)
+	delete(repo.byOrigin, u.Original)
+	repo.byUser[u.UserID] = slices.DeleteFunc(repo.byUser[u.UserID], func(s string) bool {
+		return s == short
+	})
+
+	repo.clicksMu.Lock()
+	repo.clicks = slices.DeleteFunc(repo.clicks, func(c model.Click) bool {
+		return c.Short == short
+	})
+	repo.clicksMu.Unlock()
 }
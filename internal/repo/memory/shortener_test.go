@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"testing"
+
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestClicks(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repotest.ClickStore {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestUsers(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) repotest.UserStore {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	repotest.RunAPIKeys(t, func(t *testing.T) service.APIKeyRepository {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	repotest.RunAdmin(t, func(t *testing.T) repotest.AdminStore {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
+func TestSequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) service.SequenceRepository {
+		repo, err := NewURLRepository()
+		require.NoError(t, err)
+		return repo
+	})
+}
@@ -0,0 +1,129 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shortener/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (repo *urlRepository) FindURL(ctx context.Context, short, original string) (model.URLStore, error) {
+	column, value := "short_url", short
+	if short == "" {
+		column, value = "original_url", original
+	}
+
+	var u model.URLStore
+	if err := repo.db.QueryRow(ctx,
+		fmt.Sprintf(`SELECT uuid, user_id, short_url, original_url, expires_at, is_deleted
+		FROM urls
+		WHERE %s = $1`, column),
+		value,
+	).Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.ExpiresAt, &u.DeletedFlag); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		return model.URLStore{}, fmt.Errorf("pg.FindURL error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) URLsByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	rows, err := repo.db.Query(ctx,
+		`SELECT uuid, user_id, short_url, original_url, expires_at, is_deleted
+		FROM urls
+		WHERE user_id = $1
+		ORDER BY uuid`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("pg.URLsByUser error: %w", err)
+	}
+
+	res := make([]model.URLStore, 0)
+	for rows.Next() {
+		var u model.URLStore
+		if err := rows.Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.ExpiresAt, &u.DeletedFlag); err != nil {
+			return nil, fmt.Errorf("pg.URLsByUser error: failed to scan a row: %w", err)
+		}
+		res = append(res, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pg.URLsByUser error: while reading: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) SetDeleted(ctx context.Context, short string, deleted bool) error {
+	tag, err := repo.db.Exec(ctx,
+		`UPDATE urls SET is_deleted = $2 WHERE short_url = $1`,
+		short, deleted,
+	)
+	if err != nil {
+		return fmt.Errorf("pg.SetDeleted error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) ReassignURL(ctx context.Context, short, to string) error {
+	tag, err := repo.db.Exec(ctx,
+		`UPDATE urls SET user_id = $2 WHERE short_url = $1`,
+		short, to,
+	)
+	if err != nil {
+		return fmt.Errorf("pg.ReassignURL error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) SaveAudit(ctx context.Context, e model.AuditEntry) error {
+	if _, err := repo.db.Exec(ctx,
+		`INSERT INTO audit_log (actor, action, target, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		e.Actor, e.Action, e.Target, e.Detail, e.At,
+	); err != nil {
+		return fmt.Errorf("pg.SaveAudit error: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) AuditLog(ctx context.Context, limit int) ([]model.AuditEntry, error) {
+	rows, err := repo.db.Query(ctx,
+		`SELECT id, actor, action, target, detail, created_at
+		FROM audit_log
+		ORDER BY id DESC
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("pg.AuditLog error: %w", err)
+	}
+
+	res := make([]model.AuditEntry, 0)
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.Detail, &e.At); err != nil {
+			return nil, fmt.Errorf("pg.AuditLog error: failed to scan a row: %w", err)
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pg.AuditLog error: while reading: %w", err)
+	}
+
+	return res, nil
+}
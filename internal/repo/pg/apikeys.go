@@ -0,0 +1,101 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at`
+
+func (repo *urlRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	if _, err := repo.db.Exec(ctx,
+		`INSERT INTO api_keys (`+apiKeyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.Hash, k.Scopes, k.CreatedAt, k.ExpiresAt, k.LastUsedAt,
+	); err != nil {
+		return fmt.Errorf("pg.CreateAPIKey error: insert: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) APIKeysByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	rows, err := repo.db.Query(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("pg.APIKeysByUser error: %w", err)
+	}
+
+	res := make([]model.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("pg.APIKeysByUser error: failed to scan a row: %w", err)
+		}
+		res = append(res, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pg.APIKeysByUser error: while reading: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) APIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	k, err := scanAPIKey(repo.db.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`,
+		hash,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.APIKey{}, model.ErrAPIKeyNotFound
+		}
+		return model.APIKey{}, fmt.Errorf("pg.APIKeyByHash error: %w", err)
+	}
+
+	return k, nil
+}
+
+func (repo *urlRepository) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	tag, err := repo.db.Exec(ctx,
+		`DELETE FROM api_keys WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("pg.DeleteAPIKey error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	tag, err := repo.db.Exec(ctx,
+		`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`,
+		id, at,
+	)
+	if err != nil {
+		return fmt.Errorf("pg.TouchAPIKey error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (model.APIKey, error) {
+	var k model.APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Hash, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt)
+	return k, err
+}
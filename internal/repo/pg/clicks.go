@@ -0,0 +1,94 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"shortener/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (repo *urlRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if _, err := repo.db.CopyFrom(ctx,
+		pgx.Identifier{"clicks"},
+		[]string{"short_url", "clicked_at", "referrer", "user_agent", "ip_hash", "accept_language", "country"},
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+			c := clicks[i]
+			return []any{c.Short, c.Time, c.Referrer, c.UserAgent, c.IPHash, c.AcceptLanguage, c.Country}, nil
+		}),
+	); err != nil {
+		return fmt.Errorf("pg.SaveClicks error: copy: %w", err)
+	}
+
+	return nil
+}
+
+// topColumns are the clicks columns ranked in LinkStats, in the order
+// the batch in ClickStats queries them.
+var topColumns = []string{"referrer", "country", "user_agent"}
+
+func (repo *urlRepository) ClickStats(ctx context.Context, short string, f model.StatsFilter) (model.LinkStats, error) {
+	res := model.LinkStats{Short: short, From: f.From, To: f.To, Bucket: f.Bucket}
+
+	batch := &pgx.Batch{}
+	batch.Queue(
+		`SELECT count(*), count(DISTINCT NULLIF(ip_hash, ''))
+		FROM clicks
+		WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3`,
+		short, f.From, f.To,
+	).QueryRow(func(row pgx.Row) error {
+		return row.Scan(&res.TotalClicks, &res.UniqueVisitors)
+	})
+
+	res.Series = make([]model.StatsPoint, 0)
+	batch.Queue(
+		`SELECT date_trunc($4, clicked_at AT TIME ZONE 'UTC') AS bucket,
+			count(*), count(DISTINCT NULLIF(ip_hash, ''))
+		FROM clicks
+		WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3
+		GROUP BY bucket
+		ORDER BY bucket`,
+		short, f.From, f.To, f.Bucket,
+	).Query(func(rows pgx.Rows) error {
+		for rows.Next() {
+			var p model.StatsPoint
+			if err := rows.Scan(&p.Time, &p.Clicks, &p.UniqueVisitors); err != nil {
+				return err
+			}
+			p.Time = p.Time.UTC()
+			res.Series = append(res.Series, p)
+		}
+		return rows.Err()
+	})
+
+	tops := []*[]model.StatsEntry{&res.TopReferrers, &res.TopCountries, &res.TopUserAgents}
+	for i, col := range topColumns {
+		dst := tops[i]
+		*dst = make([]model.StatsEntry, 0, f.Top)
+		batch.Queue(
+			fmt.Sprintf(`SELECT %[1]s, count(*) AS n
+			FROM clicks
+			WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3 AND %[1]s <> ''
+			GROUP BY %[1]s
+			ORDER BY n DESC, %[1]s
+			LIMIT $4`, col),
+			short, f.From, f.To, f.Top,
+		).Query(func(rows pgx.Rows) error {
+			for rows.Next() {
+				var e model.StatsEntry
+				if err := rows.Scan(&e.Value, &e.Count); err != nil {
+					return err
+				}
+				*dst = append(*dst, e)
+			}
+			return rows.Err()
+		})
+	}
+
+	if err := repo.db.SendBatch(ctx, batch).Close(); err != nil {
+		return model.LinkStats{}, fmt.Errorf("pg.ClickStats error: %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"embed"
+	"io/fs"
+
+	"shortener/internal/shared/database/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrationLockID is the advisory lock key held while migrating.
+const migrationLockID migrate.PostgresLocker = 0x73686f7274
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// NewMigrator migrates through a database/sql handle on the pool, which
+// Close releases; the pool stays open.
+func NewMigrator(db *pgxpool.Pool) (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB := stdlib.OpenDBFromPool(db)
+	m, err := migrate.New(sqlDB, sub, migrate.WithLocker(migrationLockID), migrate.OwnDB())
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS urls_user_id_idx;
+DROP INDEX IF EXISTS urls_short_url_key;
+
+-- Fails if codes longer than 10 characters have been stored since.
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(10);
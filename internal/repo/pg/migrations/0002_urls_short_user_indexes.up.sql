@@ -0,0 +1,18 @@
+-- Fails if codes longer than 10 characters have been stored since.
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(10);
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(64);
+
+-- Codes were not unique before this migration. The oldest link keeps a
+-- duplicated code; the others are renamed to <code>-<uuid> so that the
+-- index can be built.
+UPDATE urls SET short_url = short_url || '-' || uuid
+WHERE uuid IN (
+	SELECT uuid FROM (
+		SELECT uuid, row_number() OVER (PARTITION BY short_url ORDER BY uuid) AS n
+		FROM urls
+	) AS dup
+	WHERE n > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS urls_short_url_key ON urls (short_url);
+CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS urls_purge_clicks ON urls;
+DROP FUNCTION IF EXISTS purge_url_clicks();
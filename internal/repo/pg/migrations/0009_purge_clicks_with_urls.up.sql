@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION purge_url_clicks() RETURNS trigger AS $$
+BEGIN
+	DELETE FROM clicks WHERE short_url = OLD.short_url;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS urls_purge_clicks ON urls;
+CREATE TRIGGER urls_purge_clicks AFTER DELETE ON urls
+	FOR EACH ROW EXECUTE FUNCTION purge_url_clicks();
+
+DELETE FROM clicks WHERE short_url NOT IN (SELECT short_url FROM urls);
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS link_codes_seq;
@@ -0,0 +1,15 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+func (repo *urlRepository) NextSequence(ctx context.Context) (int64, error) {
+	var n int64
+	if err := repo.db.QueryRow(ctx, `SELECT nextval('link_codes_seq')`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("pg.NextSequence error: %w", err)
+	}
+
+	return n, nil
+}
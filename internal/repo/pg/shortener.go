@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"shortener/internal/model"
 
@@ -14,6 +15,9 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// shortURLKey is the unique index on urls.short_url, see migrations.
+const shortURLKey = "urls_short_url_key"
+
 type urlRepository struct {
 	db *pgxpool.Pool
 }
@@ -26,33 +30,48 @@ func NewURLRepository(ctx context.Context, db *pgxpool.Pool) (*urlRepository, er
 }
 
 func bootstrap(ctx context.Context, db *pgxpool.Pool) error {
-	_, err := db.Exec(ctx,
-		`CREATE TABLE IF NOT EXISTS urls (
-		 	uuid SERIAL NOT NULL PRIMARY KEY,
-			user_id VARCHAR(50) NOT NULL,
-			short_url VARCHAR(10) NOT NULL,
-			original_url VARCHAR NOT NULL UNIQUE,
-			is_deleted BOOLEAN NOT NULL DEFAULT false
-		 )`,
-	)
+	m, err := NewMigrator(db)
 	if err != nil {
-		return err
+		return fmt.Errorf("pg.bootstrap error: load migrations: %w", err)
+	}
+	defer m.Close()
+
+	if _, err := m.Up(ctx); err != nil {
+		return fmt.Errorf("pg.bootstrap error: %w", err)
 	}
 
 	return nil
 }
 
+// Close closes the connection pool.
+func (repo *urlRepository) Close() error {
+	repo.db.Close()
+	return nil
+}
+
 func (repo *urlRepository) Ping(ctx context.Context) error { return repo.db.Ping(ctx) }
 
 func (repo *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
+	// Expired rows that have not been reaped yet must not block the
+	// original URL or the short code from being taken again.
+	if _, err := repo.db.Exec(ctx,
+		`DELETE FROM urls
+		WHERE (original_url = $1 OR short_url = $2) AND expires_at <= now()`,
+		u.Original, u.Short,
+	); err != nil {
+		return "", fmt.Errorf("pg.Save error: purge expired: %w", err)
+	}
+
 	_, err := repo.db.Exec(ctx,
-		`INSERT INTO urls (user_id, short_url, original_url)
-			VALUES ($1, $2, $3);`,
-		u.UserID, u.Short, u.Original,
+		`INSERT INTO urls (user_id, short_url, original_url, expires_at)
+			VALUES ($1, $2, $3, $4);`,
+		u.UserID, u.Short, u.Original, u.ExpiresAt,
 	)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if isShortViolation(err) {
+			return "", model.ErrShortExists
+		}
+		if isUniqueViolation(err) {
 			var shortURL string
 			if err := repo.db.QueryRow(ctx,
 				"SELECT short_url FROM urls WHERE original_url = $1",
@@ -75,23 +94,43 @@ func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) e
 	}
 	defer tx.Rollback(ctx)
 
+	originals := make([]string, len(urls))
+	shorts := make([]string, len(urls))
+	for i, u := range urls {
+		originals[i], shorts[i] = u.Original, u.Short
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM urls
+		WHERE (original_url = ANY($1) OR short_url = ANY($2)) AND expires_at <= now()`,
+		originals, shorts,
+	); err != nil {
+		return fmt.Errorf("pg.SaveAll error: purge expired: %w", err)
+	}
+
 	stmtName := "insert_URL"
 	if _, err := tx.Prepare(ctx, stmtName,
-		`INSERT INTO urls (user_id, short_url, original_url)
-				VALUES ($1, $2, $3);`,
+		`INSERT INTO urls (user_id, short_url, original_url, expires_at)
+				VALUES ($1, $2, $3, $4);`,
 	); err != nil {
 		return fmt.Errorf("pg.SaveAll error: create a statement: %w", err)
 	}
 
 	batch := &pgx.Batch{}
 	for _, u := range urls {
-		batch.Queue(stmtName, u.UserID, u.Short, u.Original)
+		batch.Queue(stmtName, u.UserID, u.Short, u.Original, u.ExpiresAt)
 	}
 
 	br := tx.SendBatch(ctx, batch)
 	for range urls {
 		res, err := br.Exec()
 		if err != nil {
+			br.Close()
+			if isShortViolation(err) {
+				return model.ErrShortExists
+			}
+			if isUniqueViolation(err) {
+				return model.ErrURLAlreadyExists
+			}
 			return fmt.Errorf("pg.SaveAll error: batch execute: %w", err)
 		}
 		if res.RowsAffected() == 0 {
@@ -112,17 +151,23 @@ func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) e
 func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
 	var u model.URLStore
 	if err := repo.db.QueryRow(ctx,
-		`SELECT uuid, short_url, original_url, is_deleted
+		`SELECT uuid, user_id, short_url, original_url, expires_at, is_deleted
 		FROM urls
 		WHERE short_url = $1`,
 		short,
-	).Scan(&u.UUID, &u.Short, &u.Original, &u.DeletedFlag); err != nil {
+	).Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.ExpiresAt, &u.DeletedFlag); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
 		return model.URLStore{}, fmt.Errorf("pg.Get error: failed to find a row: %w", err)
 	}
 
 	if u.DeletedFlag {
 		return model.URLStore{}, model.ErrDeleted
 	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
 
 	return u, nil
 }
@@ -130,26 +175,33 @@ func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStor
 func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
 	var u model.URLStore
 	if err := repo.db.QueryRow(ctx,
-		`SELECT uuid, user_id, short_url, original_url, is_deleted
+		`SELECT uuid, user_id, short_url, original_url, expires_at, is_deleted
 		FROM urls
 		WHERE uuid = $1`,
 		uuid,
-	).Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.DeletedFlag); err != nil {
+	).Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.ExpiresAt, &u.DeletedFlag); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
 		return model.URLStore{}, fmt.Errorf("pg.GetByID error: failed to find a row: %w", err)
 	}
 
 	if u.DeletedFlag {
 		return model.URLStore{}, model.ErrDeleted
 	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
 
 	return u, nil
 }
 
 func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
 	rows, err := repo.db.Query(ctx,
-		`SELECT short_url, original_url
+		`SELECT short_url, original_url, expires_at
 		FROM urls
-		WHERE user_id = $1 and is_deleted = false`,
+		WHERE user_id = $1 and is_deleted = false
+			AND (expires_at IS NULL OR expires_at > now())`,
 		userID,
 	)
 	if err != nil {
@@ -159,7 +211,7 @@ func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]m
 	res := make([]model.URLStore, 0)
 	for rows.Next() {
 		var u model.URLStore
-		if err := rows.Scan(&u.Short, &u.Original); err != nil {
+		if err := rows.Scan(&u.Short, &u.Original, &u.ExpiresAt); err != nil {
 			return []model.URLStore{}, fmt.Errorf("pg.GetAllByUser error: failed to scan a row: %w", err)
 		}
 
@@ -184,3 +236,57 @@ func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls
 
 	return nil
 }
+
+func (repo *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	tag, err := repo.db.Exec(ctx,
+		`DELETE FROM urls WHERE expires_at <= $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("pg.DeleteExpired error: delete: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (repo *urlRepository) Stats(ctx context.Context) (model.ServiceStats, error) {
+	var res model.ServiceStats
+	if err := repo.db.QueryRow(ctx,
+		`SELECT count(*),
+			count(DISTINCT user_id),
+			count(*) FILTER (WHERE NOT is_deleted AND (expires_at IS NULL OR expires_at > now())),
+			count(*) FILTER (WHERE is_deleted),
+			count(*) FILTER (WHERE NOT is_deleted AND expires_at <= now())
+		FROM urls`,
+	).Scan(&res.URLs, &res.Users, &res.Live, &res.Deleted, &res.Expired); err != nil {
+		return model.ServiceStats{}, fmt.Errorf("pg.Stats error: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	tag, err := repo.db.Exec(ctx,
+		`UPDATE urls SET user_id = $2 WHERE user_id = $1`,
+		from, to,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("pg.ReassignUser error: update: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
+
+// isShortViolation reports a clash on the short_url index as opposed to
+// the original_url one.
+func isShortViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		pgErr.Code == pgerrcode.UniqueViolation &&
+		pgErr.ConstraintName == shortURLKey
+}
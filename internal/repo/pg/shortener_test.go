@@ -0,0 +1,112 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// testDSNEnv names the variable holding a DSN of a disposable database.
+// The suite is skipped when it is unset.
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+// newTestPool connects to the test database with search_path pointed at
+// a fresh schema that is dropped when the test ends.
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+
+	admin, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer admin.Close(ctx)
+
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	require.NoError(t, err)
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		db.Close()
+
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+			return
+		}
+		defer conn.Close(ctx)
+
+		if _, err := conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	return db
+}
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestClicks(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repotest.ClickStore {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestUsers(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) repotest.UserStore {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	repotest.RunAPIKeys(t, func(t *testing.T) service.APIKeyRepository {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	repotest.RunAdmin(t, func(t *testing.T) repotest.AdminStore {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
+func TestSequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) service.SequenceRepository {
+		repo, err := NewURLRepository(context.Background(), newTestPool(t))
+		require.NoError(t, err)
+		return repo
+	})
+}
@@ -0,0 +1,62 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shortener/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (repo *urlRepository) CreateUser(ctx context.Context, u model.User) error {
+	if _, err := repo.db.Exec(ctx,
+		`INSERT INTO users (id, email, password_hash, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		u.ID, u.Email, u.PasswordHash, u.Role, u.CreatedAt,
+	); err != nil {
+		if isUniqueViolation(err) {
+			return model.ErrUserExists
+		}
+		return fmt.Errorf("pg.CreateUser error: insert: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) UserByEmail(ctx context.Context, email string) (model.User, error) {
+	return repo.user(ctx, "email", email)
+}
+
+func (repo *urlRepository) UserByID(ctx context.Context, id string) (model.User, error) {
+	return repo.user(ctx, "id", id)
+}
+
+// user looks a user up by a unique column.
+func (repo *urlRepository) user(ctx context.Context, column, value string) (model.User, error) {
+	var u model.User
+	if err := repo.db.QueryRow(ctx,
+		fmt.Sprintf(`SELECT id, email, password_hash, role, created_at FROM users WHERE %s = $1`, column),
+		value,
+	).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, fmt.Errorf("pg.user error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) SetRole(ctx context.Context, userID, role string) error {
+	tag, err := repo.db.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1`, userID, role)
+	if err != nil {
+		return fmt.Errorf("pg.SetRole error: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
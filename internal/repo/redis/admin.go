@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func (repo *urlRepository) FindURL(ctx context.Context, short, original string) (model.URLStore, error) {
+	if short == "" {
+		var err error
+		short, err = repo.db.HGet(ctx, repo.key("origins"), original).Result()
+		if errors.Is(err, goredis.Nil) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		if err != nil {
+			return model.URLStore{}, fmt.Errorf("redis.FindURL error: %w", err)
+		}
+	}
+
+	return repo.load(ctx, short)
+}
+
+func (repo *urlRepository) URLsByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	urls, err := repo.byUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("redis.URLsByUser error: %w", err)
+	}
+
+	return urls, nil
+}
+
+func (repo *urlRepository) SetDeleted(ctx context.Context, short string, deleted bool) error {
+	flag := ""
+	if deleted {
+		flag = "1"
+	}
+	ok, err := repo.run(ctx, setDeletedScript, time.Now(), short, flag).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.SetDeleted error: %w", err)
+	}
+	if !ok {
+		return model.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) ReassignURL(ctx context.Context, short, to string) error {
+	ok, err := repo.run(ctx, reassignURLScript, time.Now(), short, to).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.ReassignURL error: %w", err)
+	}
+	if !ok {
+		return model.ErrURLNotFound
+	}
+
+	return nil
+}
+
+// The audit log is a sorted set of JSON entries scored by ID, so entries
+// stay in order even when replicas write at the same time.
+func (repo *urlRepository) SaveAudit(ctx context.Context, e model.AuditEntry) error {
+	id, err := repo.db.Incr(ctx, repo.key("audit_seq")).Result()
+	if err != nil {
+		return fmt.Errorf("redis.SaveAudit error: %w", err)
+	}
+
+	e.ID = id
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("redis.SaveAudit error: %w", err)
+	}
+	if err := repo.db.ZAdd(ctx, repo.key("audit"), goredis.Z{Score: float64(id), Member: b}).Err(); err != nil {
+		return fmt.Errorf("redis.SaveAudit error: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) AuditLog(ctx context.Context, limit int) ([]model.AuditEntry, error) {
+	raw, err := repo.db.ZRevRange(ctx, repo.key("audit"), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis.AuditLog error: %w", err)
+	}
+
+	res := make([]model.AuditEntry, 0, len(raw))
+	for _, s := range raw {
+		var e model.AuditEntry
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			return nil, fmt.Errorf("redis.AuditLog error: decode entry: %w", err)
+		}
+		res = append(res, e)
+	}
+
+	return res, nil
+}
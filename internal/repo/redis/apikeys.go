@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// API keys are hashes under apikey:<id>, indexed by the apikey_hashes
+// hash and an apikeys:<user> set per owner.
+func (repo *urlRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	fields := []any{
+		"user", k.UserID,
+		"name", k.Name,
+		"prefix", k.Prefix,
+		"hash", k.Hash,
+		"scopes", strings.Join(k.Scopes, ","),
+		"created_at", k.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if k.ExpiresAt != nil {
+		fields = append(fields, "expires_at", k.ExpiresAt.Format(time.RFC3339Nano))
+	}
+
+	if _, err := repo.db.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSet(ctx, repo.key("apikey", k.ID), fields...)
+		pipe.HSet(ctx, repo.key("apikey_hashes"), k.Hash, k.ID)
+		pipe.SAdd(ctx, repo.key("apikeys", k.UserID), k.ID)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("redis.CreateAPIKey error: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) APIKeysByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	ids, err := repo.db.SMembers(ctx, repo.key("apikeys", userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis.APIKeysByUser error: %w", err)
+	}
+
+	cmds := make([]*goredis.MapStringStringCmd, len(ids))
+	if _, err := repo.db.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, repo.key("apikey", id))
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("redis.APIKeysByUser error: %w", err)
+	}
+
+	res := make([]model.APIKey, 0, len(ids))
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		k, err := decodeAPIKey(ids[i], cmd.Val())
+		if err != nil {
+			return nil, fmt.Errorf("redis.APIKeysByUser error: %w", err)
+		}
+		res = append(res, k)
+	}
+	slices.SortFunc(res, func(a, b model.APIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return res, nil
+}
+
+func (repo *urlRepository) APIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	id, err := repo.db.HGet(ctx, repo.key("apikey_hashes"), hash).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return model.APIKey{}, model.ErrAPIKeyNotFound
+		}
+		return model.APIKey{}, fmt.Errorf("redis.APIKeyByHash error: %w", err)
+	}
+
+	m, err := repo.db.HGetAll(ctx, repo.key("apikey", id)).Result()
+	if err != nil {
+		return model.APIKey{}, fmt.Errorf("redis.APIKeyByHash error: %w", err)
+	}
+	if len(m) == 0 {
+		return model.APIKey{}, model.ErrAPIKeyNotFound
+	}
+
+	k, err := decodeAPIKey(id, m)
+	if err != nil {
+		return model.APIKey{}, fmt.Errorf("redis.APIKeyByHash error: %w", err)
+	}
+	return k, nil
+}
+
+func (repo *urlRepository) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	ok, err := deleteAPIKeyScript.Run(ctx, repo.db,
+		[]string{repo.key("apikey", id), repo.key("apikey_hashes"), repo.key("apikeys", userID)},
+		userID, id,
+	).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.DeleteAPIKey error: %w", err)
+	}
+	if !ok {
+		return model.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	ok, err := hsetIfExistsScript.Run(ctx, repo.db,
+		[]string{repo.key("apikey", id)},
+		"last_used_at", at.Format(time.RFC3339Nano),
+	).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.TouchAPIKey error: %w", err)
+	}
+	if !ok {
+		return model.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func decodeAPIKey(id string, m map[string]string) (model.APIKey, error) {
+	k := model.APIKey{
+		ID:     id,
+		UserID: m["user"],
+		Name:   m["name"],
+		Prefix: m["prefix"],
+		Hash:   m["hash"],
+	}
+	if m["scopes"] != "" {
+		k.Scopes = strings.Split(m["scopes"], ",")
+	}
+
+	var err error
+	if k.CreatedAt, err = time.Parse(time.RFC3339Nano, m["created_at"]); err != nil {
+		return model.APIKey{}, fmt.Errorf("decode key %s: created_at: %w", id, err)
+	}
+	if k.ExpiresAt, err = optionalTime(m, "expires_at"); err != nil {
+		return model.APIKey{}, fmt.Errorf("decode key %s: %w", id, err)
+	}
+	if k.LastUsedAt, err = optionalTime(m, "last_used_at"); err != nil {
+		return model.APIKey{}, fmt.Errorf("decode key %s: %w", id, err)
+	}
+
+	return k, nil
+}
+
+func optionalTime(m map[string]string, field string) (*time.Time, error) {
+	v, ok := m[field]
+	if !ok {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/clickstat"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Click dimensions, as field name prefixes of the per-hour hashes.
+const (
+	dimReferrer  = "referrer"
+	dimUserAgent = "user_agent"
+	dimCountry   = "country"
+)
+
+// Clicks are counted as they are recorded, per link and hour, so that
+// reading statistics costs one lookup per hour with clicks in the range
+// whatever the number of clicks:
+//
+//	clicks:<short>           hash: hour → clicks
+//	clicks:<short>:<hour>    hash: <dimension>:<value> → clicks
+//	visitors:<short>:<hour>  HyperLogLog of the visitors' IP hashes
+//
+// Hours are Unix timestamps. Statistics are therefore precise to the
+// hour, and unique visitors are an estimate with a standard error of
+// 0.81%.
+func (repo *urlRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if _, err := repo.db.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, c := range clicks {
+			hour := clickHour(c.Time)
+			pipe.HIncrBy(ctx, repo.key("clicks", c.Short), hour, 1)
+
+			dims := repo.key("clicks", c.Short, hour)
+			for _, d := range [][2]string{
+				{dimReferrer, c.Referrer},
+				{dimUserAgent, c.UserAgent},
+				{dimCountry, c.Country},
+			} {
+				if d[1] != "" {
+					pipe.HIncrBy(ctx, dims, d[0]+":"+d[1], 1)
+				}
+			}
+			if c.IPHash != "" {
+				pipe.PFAdd(ctx, repo.key("visitors", c.Short, hour), c.IPHash)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("redis.SaveClicks error: %w", err)
+	}
+
+	return nil
+}
+
+// ClickStats counts the hours that start in [From, To), From rounded
+// down to the hour.
+func (repo *urlRepository) ClickStats(ctx context.Context, short string, f model.StatsFilter) (model.LinkStats, error) {
+	counts, err := repo.db.HGetAll(ctx, repo.key("clicks", short)).Result()
+	if err != nil {
+		return model.LinkStats{}, fmt.Errorf("redis.ClickStats error: %w", err)
+	}
+
+	type hour struct {
+		key    string
+		start  time.Time
+		clicks int
+	}
+	from := f.From.UTC().Truncate(time.Hour)
+	hours := make([]hour, 0, len(counts))
+	for h, n := range counts {
+		sec, err := strconv.ParseInt(h, 10, 64)
+		if err != nil {
+			return model.LinkStats{}, fmt.Errorf("redis.ClickStats error: bad hour %q: %w", h, err)
+		}
+		start := time.Unix(sec, 0).UTC()
+		if start.Before(from) || !start.Before(f.To) {
+			continue
+		}
+		clicks, err := strconv.Atoi(n)
+		if err != nil {
+			return model.LinkStats{}, fmt.Errorf("redis.ClickStats error: bad count %q: %w", n, err)
+		}
+		hours = append(hours, hour{key: h, start: start, clicks: clicks})
+	}
+	slices.SortFunc(hours, func(a, b hour) int { return a.start.Compare(b.start) })
+
+	res := model.LinkStats{Short: short, From: f.From, To: f.To, Bucket: f.Bucket, Series: []model.StatsPoint{}}
+	var (
+		dimCmds  = make([]*goredis.MapStringStringCmd, len(hours))
+		visitors = make([]string, len(hours))
+		// bucketCmds counts the visitors of each point of the series.
+		bucketCmds []*goredis.IntCmd
+		totalCmd   *goredis.IntCmd
+	)
+	if len(hours) > 0 {
+		if _, err := repo.db.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+			first := 0
+			for i, h := range hours {
+				dimCmds[i] = pipe.HGetAll(ctx, repo.key("clicks", short, h.key))
+				visitors[i] = repo.key("visitors", short, h.key)
+
+				res.TotalClicks += h.clicks
+				bucket := f.BucketStart(h.start)
+				if i == 0 || !bucket.Equal(res.Series[len(res.Series)-1].Time) {
+					res.Series = append(res.Series, model.StatsPoint{Time: bucket})
+					first = i
+				}
+				res.Series[len(res.Series)-1].Clicks += h.clicks
+				if i == len(hours)-1 || !f.BucketStart(hours[i+1].start).Equal(bucket) {
+					bucketCmds = append(bucketCmds, pipe.PFCount(ctx, visitors[first:i+1]...))
+				}
+			}
+			totalCmd = pipe.PFCount(ctx, visitors...)
+			return nil
+		}); err != nil {
+			return model.LinkStats{}, fmt.Errorf("redis.ClickStats error: %w", err)
+		}
+		res.UniqueVisitors = int(totalCmd.Val())
+		for i, cmd := range bucketCmds {
+			res.Series[i].UniqueVisitors = int(cmd.Val())
+		}
+	}
+
+	tops := map[string]map[string]int{dimReferrer: {}, dimUserAgent: {}, dimCountry: {}}
+	for _, cmd := range dimCmds {
+		for field, n := range cmd.Val() {
+			dim, value, _ := strings.Cut(field, ":")
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return model.LinkStats{}, fmt.Errorf("redis.ClickStats error: bad count %q: %w", n, err)
+			}
+			if m, ok := tops[dim]; ok {
+				m[value] += count
+			}
+		}
+	}
+	res.TopReferrers = clickstat.Top(tops[dimReferrer], f.Top)
+	res.TopUserAgents = clickstat.Top(tops[dimUserAgent], f.Top)
+	res.TopCountries = clickstat.Top(tops[dimCountry], f.Top)
+
+	return res, nil
+}
+
+func clickHour(t time.Time) string {
+	return strconv.FormatInt(t.UTC().Truncate(time.Hour).Unix(), 10)
+}
@@ -0,0 +1,229 @@
+package redis
+
+import goredis "github.com/redis/go-redis/v9"
+
+// Link scripts take the key prefix as ARGV[1] and the current time in
+// Unix microseconds as ARGV[2]; their own arguments follow. They build
+// key names themselves because the keys a write touches, such as the
+// link set of a previous owner, are only known once the record is read.
+// This rules out Redis Cluster.
+//
+// Layout, under the prefix:
+//
+//	url:<short>     hash: uuid, user, original, [expires], [deleted]
+//	ids             hash: uuid → short
+//	origins         hash: original URL → short
+//	links:<user>    set of a user's shorts
+//	owners          set of users with links
+//	deleted         set of deleted shorts
+//	expiring        sorted set of shorts by expiry
+//	seq             UUID counter
+//	codes           counter of the counter code strategy
+//
+// Clicks are laid out in clicks.go; purge drops them with the link.
+const prelude = `
+local p = ARGV[1]
+local now = tonumber(ARGV[2])
+
+local function urlKey(short) return p .. 'url:' .. short end
+local function linksKey(user) return p .. 'links:' .. user end
+
+local function link(user, short)
+	redis.call('SADD', linksKey(user), short)
+	redis.call('SADD', p .. 'owners', user)
+end
+
+local function unlink(user, short)
+	redis.call('SREM', linksKey(user), short)
+	if redis.call('SCARD', linksKey(user)) == 0 then
+		redis.call('SREM', p .. 'owners', user)
+	end
+end
+
+local function expired(short)
+	local exp = redis.call('HGET', urlKey(short), 'expires')
+	return exp and tonumber(exp) <= now
+end
+
+local function purgeClicks(short)
+	local clicks = p .. 'clicks:' .. short
+	for _, hour in ipairs(redis.call('HKEYS', clicks)) do
+		redis.call('DEL', clicks .. ':' .. hour, p .. 'visitors:' .. short .. ':' .. hour)
+	end
+	redis.call('DEL', clicks)
+end
+
+local function purge(short)
+	local f = redis.call('HMGET', urlKey(short), 'uuid', 'user', 'original')
+	if not f[1] then return end
+	purgeClicks(short)
+	redis.call('DEL', urlKey(short))
+	redis.call('HDEL', p .. 'ids', f[1])
+	redis.call('HDEL', p .. 'origins', f[3])
+	redis.call('SREM', p .. 'deleted', short)
+	redis.call('ZREM', p .. 'expiring', short)
+	unlink(f[2], short)
+end
+
+-- conflict reports why a link cannot be stored: 'exists' with the short
+-- code holding the original URL, or 'short'. Expired records that have
+-- not been reaped yet do not count: insert purges them.
+local function conflict(short, original)
+	local owner = redis.call('HGET', p .. 'origins', original)
+	if owner and not expired(owner) then return 'exists', owner end
+	if redis.call('EXISTS', urlKey(short)) == 1 and not expired(short) then return 'short' end
+	return nil
+end
+
+local function insert(short, user, original, expires)
+	local owner = redis.call('HGET', p .. 'origins', original)
+	if owner then purge(owner) end
+	purge(short)
+
+	local id = redis.call('INCR', p .. 'seq')
+	redis.call('HSET', urlKey(short), 'uuid', id, 'user', user, 'original', original)
+	if expires ~= '' then
+		redis.call('HSET', urlKey(short), 'expires', expires)
+		redis.call('ZADD', p .. 'expiring', expires, short)
+	end
+	redis.call('HSET', p .. 'ids', id, short)
+	redis.call('HSET', p .. 'origins', original, short)
+	link(user, short)
+end
+`
+
+// saveScript stores one link: ARGV[3..6] are short, user, original and
+// expires. It returns {code, short}, where code is ok, exists or short.
+var saveScript = goredis.NewScript(prelude + `
+local code, owner = conflict(ARGV[3], ARGV[5])
+if code then return {code, owner or ''} end
+insert(ARGV[3], ARGV[4], ARGV[5], ARGV[6])
+return {'ok', ARGV[3]}
+`)
+
+// saveAllScript stores links given as groups of four arguments like
+// saveScript's. The batch is checked as a whole before anything is
+// written, so a conflict leaves the store untouched.
+var saveAllScript = goredis.NewScript(prelude + `
+local origins, shorts = {}, {}
+for i = 3, #ARGV, 4 do
+	local short, original = ARGV[i], ARGV[i + 2]
+	local code, owner = conflict(short, original)
+	if code then return {code, owner or ''} end
+	if origins[original] then return {'exists', ''} end
+	if shorts[short] then return {'short', ''} end
+	origins[original], shorts[short] = true, true
+end
+for i = 3, #ARGV, 4 do
+	insert(ARGV[i], ARGV[i + 1], ARGV[i + 2], ARGV[i + 3])
+end
+return {'ok', ''}
+`)
+
+// deleteBatchScript marks the shorts in ARGV[4..] deleted if they belong
+// to the user in ARGV[3].
+var deleteBatchScript = goredis.NewScript(prelude + `
+for i = 4, #ARGV do
+	if redis.call('HGET', urlKey(ARGV[i]), 'user') == ARGV[3] then
+		redis.call('HSET', urlKey(ARGV[i]), 'deleted', '1')
+		redis.call('SADD', p .. 'deleted', ARGV[i])
+	end
+end
+return 0
+`)
+
+// deleteExpiredScript purges links expired at ARGV[2] and returns how
+// many there were.
+var deleteExpiredScript = goredis.NewScript(prelude + `
+local gone = redis.call('ZRANGEBYSCORE', p .. 'expiring', '-inf', now)
+for _, short in ipairs(gone) do
+	purge(short)
+end
+return #gone
+`)
+
+// statsScript returns {urls, users, live, deleted, expired}. A link that
+// is both deleted and expired counts as deleted.
+var statsScript = goredis.NewScript(prelude + `
+local urls = redis.call('HLEN', p .. 'ids')
+local deleted = redis.call('SCARD', p .. 'deleted')
+local expired = 0
+for _, short in ipairs(redis.call('ZRANGEBYSCORE', p .. 'expiring', '-inf', now)) do
+	if redis.call('SISMEMBER', p .. 'deleted', short) == 0 then
+		expired = expired + 1
+	end
+end
+return {urls, redis.call('SCARD', p .. 'owners'), urls - deleted - expired, deleted, expired}
+`)
+
+// reassignUserScript moves every link of ARGV[3] to ARGV[4] and returns
+// how many were moved.
+var reassignUserScript = goredis.NewScript(prelude + `
+local from, to = ARGV[3], ARGV[4]
+local moved = redis.call('SMEMBERS', linksKey(from))
+if from == to then return #moved end
+for _, short in ipairs(moved) do
+	redis.call('HSET', urlKey(short), 'user', to)
+	link(to, short)
+end
+redis.call('DEL', linksKey(from))
+redis.call('SREM', p .. 'owners', from)
+return #moved
+`)
+
+// reassignURLScript gives the link ARGV[3] to ARGV[4]. It returns 0 if
+// the link does not exist.
+var reassignURLScript = goredis.NewScript(prelude + `
+local from = redis.call('HGET', urlKey(ARGV[3]), 'user')
+if not from then return 0 end
+if from ~= ARGV[4] then
+	unlink(from, ARGV[3])
+	redis.call('HSET', urlKey(ARGV[3]), 'user', ARGV[4])
+	link(ARGV[4], ARGV[3])
+end
+return 1
+`)
+
+// setDeletedScript deletes the link ARGV[3] if ARGV[4] is 1 and restores
+// it otherwise. It returns 0 if the link does not exist.
+var setDeletedScript = goredis.NewScript(prelude + `
+if redis.call('EXISTS', urlKey(ARGV[3])) == 0 then return 0 end
+if ARGV[4] == '1' then
+	redis.call('HSET', urlKey(ARGV[3]), 'deleted', '1')
+	redis.call('SADD', p .. 'deleted', ARGV[3])
+else
+	redis.call('HDEL', urlKey(ARGV[3]), 'deleted')
+	redis.call('SREM', p .. 'deleted', ARGV[3])
+end
+return 1
+`)
+
+// hsetIfExistsScript sets the field/value pairs in ARGV on the hash
+// KEYS[1]. It returns 0, without creating the hash, if it does not
+// exist.
+var hsetIfExistsScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// createUserScript stores the account KEYS[2] with the field/value pairs
+// in ARGV[3..] unless the email ARGV[1] is taken in the index KEYS[1].
+// ARGV[2] is the account ID. It returns 0 if the email is taken.
+var createUserScript = goredis.NewScript(`
+if redis.call('HSETNX', KEYS[1], ARGV[1], ARGV[2]) == 0 then return 0 end
+redis.call('HSET', KEYS[2], unpack(ARGV, 3))
+return 1
+`)
+
+// deleteAPIKeyScript removes the key KEYS[1] from its hash index KEYS[2]
+// and owner set KEYS[3] if it belongs to ARGV[1]; ARGV[2] is its ID. It
+// returns 0 if the key does not exist or belongs to someone else.
+var deleteAPIKeyScript = goredis.NewScript(`
+local f = redis.call('HMGET', KEYS[1], 'user', 'hash')
+if f[1] ~= ARGV[1] then return 0 end
+redis.call('DEL', KEYS[1])
+redis.call('HDEL', KEYS[2], f[2])
+redis.call('SREM', KEYS[3], ARGV[2])
+return 1
+`)
@@ -0,0 +1,15 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+func (repo *urlRepository) NextSequence(ctx context.Context) (int64, error) {
+	n, err := repo.db.Incr(ctx, repo.key("codes")).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis.NextSequence error: %w", err)
+	}
+
+	return n, nil
+}
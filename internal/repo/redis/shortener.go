@@ -0,0 +1,261 @@
+// Package redis keeps links in Redis so that several replicas can share
+// them. Writes that touch more than one key run as Lua scripts and are
+// atomic; see scripts.go for the key layout.
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// keyPrefix keeps the keys apart from those of other applications
+// sharing the server.
+const keyPrefix = "shortener:"
+
+type urlRepository struct {
+	db     *goredis.Client
+	prefix string
+}
+
+func NewURLRepository(ctx context.Context, db *goredis.Client) (*urlRepository, error) {
+	if err := db.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis.NewURLRepository error: %w", err)
+	}
+	return &urlRepository{db: db, prefix: keyPrefix}, nil
+}
+
+// Close closes the client.
+func (repo *urlRepository) Close() error { return repo.db.Close() }
+
+func (repo *urlRepository) Ping(ctx context.Context) error { return repo.db.Ping(ctx).Err() }
+
+func (repo *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
+	res, err := repo.run(ctx, saveScript, time.Now(), u.Short, u.UserID, u.Original, expires(u.ExpiresAt)).StringSlice()
+	if err != nil {
+		return "", fmt.Errorf("redis.Save error: %w", err)
+	}
+
+	return res[1], saveError(res[0])
+}
+
+func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 4*len(urls))
+	for _, u := range urls {
+		args = append(args, u.Short, u.UserID, u.Original, expires(u.ExpiresAt))
+	}
+	res, err := repo.run(ctx, saveAllScript, time.Now(), args...).StringSlice()
+	if err != nil {
+		return fmt.Errorf("redis.SaveAll error: %w", err)
+	}
+
+	return saveError(res[0])
+}
+
+func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
+	u, err := repo.load(ctx, short)
+	if err != nil {
+		return model.URLStore{}, err
+	}
+
+	return check(u)
+}
+
+func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	short, err := repo.db.HGet(ctx, repo.key("ids"), strconv.Itoa(uuid)).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		return model.URLStore{}, fmt.Errorf("redis.GetByID error: %w", err)
+	}
+
+	return repo.Get(ctx, short)
+}
+
+func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	urls, err := repo.byUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("redis.GetAllByUser error: %w", err)
+	}
+
+	now := time.Now()
+	return slices.DeleteFunc(urls, func(u model.URLStore) bool {
+		return u.DeletedFlag || u.Expired(now)
+	}), nil
+}
+
+func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(urls)+1)
+	args = append(args, userID)
+	for _, short := range urls {
+		args = append(args, short)
+	}
+	if err := repo.run(ctx, deleteBatchScript, time.Now(), args...).Err(); err != nil {
+		return fmt.Errorf("redis.DeleteBatch error: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	n, err := repo.run(ctx, deleteExpiredScript, before).Int()
+	if err != nil {
+		return 0, fmt.Errorf("redis.DeleteExpired error: %w", err)
+	}
+
+	return n, nil
+}
+
+func (repo *urlRepository) Stats(ctx context.Context) (model.ServiceStats, error) {
+	res, err := repo.run(ctx, statsScript, time.Now()).Int64Slice()
+	if err != nil {
+		return model.ServiceStats{}, fmt.Errorf("redis.Stats error: %w", err)
+	}
+
+	return model.ServiceStats{
+		URLs:    int(res[0]),
+		Users:   int(res[1]),
+		Live:    int(res[2]),
+		Deleted: int(res[3]),
+		Expired: int(res[4]),
+	}, nil
+}
+
+func (repo *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	n, err := repo.run(ctx, reassignUserScript, time.Now(), from, to).Int()
+	if err != nil {
+		return 0, fmt.Errorf("redis.ReassignUser error: %w", err)
+	}
+
+	return n, nil
+}
+
+// run evaluates a link script; see scripts.go for its arguments.
+func (repo *urlRepository) run(ctx context.Context, s *goredis.Script, now time.Time, args ...any) *goredis.Cmd {
+	return s.Run(ctx, repo.db, nil, append([]any{repo.prefix, now.UnixMicro()}, args...)...)
+}
+
+func (repo *urlRepository) key(parts ...string) string {
+	return repo.prefix + strings.Join(parts, ":")
+}
+
+// load reads a link whatever its state.
+func (repo *urlRepository) load(ctx context.Context, short string) (model.URLStore, error) {
+	m, err := repo.db.HGetAll(ctx, repo.key("url", short)).Result()
+	if err != nil {
+		return model.URLStore{}, fmt.Errorf("redis.load error: %w", err)
+	}
+	if len(m) == 0 {
+		return model.URLStore{}, model.ErrURLNotFound
+	}
+
+	return decodeURL(short, m)
+}
+
+// byUser reads every link of userID whatever its state, oldest first.
+func (repo *urlRepository) byUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	shorts, err := repo.db.SMembers(ctx, repo.key("links", userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*goredis.MapStringStringCmd, len(shorts))
+	if _, err := repo.db.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, short := range shorts {
+			cmds[i] = pipe.HGetAll(ctx, repo.key("url", short))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	res := make([]model.URLStore, 0, len(shorts))
+	for i, cmd := range cmds {
+		// A link purged between the two reads is skipped.
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		u, err := decodeURL(shorts[i], cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, u)
+	}
+	slices.SortFunc(res, func(a, b model.URLStore) int { return a.UUID - b.UUID })
+
+	return res, nil
+}
+
+func decodeURL(short string, m map[string]string) (model.URLStore, error) {
+	id, err := strconv.Atoi(m["uuid"])
+	if err != nil {
+		return model.URLStore{}, fmt.Errorf("decode %s: uuid: %w", short, err)
+	}
+
+	u := model.URLStore{
+		UUID:        id,
+		UserID:      m["user"],
+		Short:       short,
+		Original:    m["original"],
+		DeletedFlag: m["deleted"] == "1",
+	}
+	if v, ok := m["expires"]; ok {
+		us, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return model.URLStore{}, fmt.Errorf("decode %s: expires: %w", short, err)
+		}
+		t := time.UnixMicro(us).UTC()
+		u.ExpiresAt = &t
+	}
+
+	return u, nil
+}
+
+// expires encodes an expiry in Unix microseconds, which Lua numbers hold
+// exactly; no expiry is an empty string.
+func expires(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+func saveError(code string) error {
+	switch code {
+	case "ok":
+		return nil
+	case "exists":
+		return model.ErrURLAlreadyExists
+	case "short":
+		return model.ErrShortExists
+	}
+	return fmt.Errorf("redis: unexpected script result %q", code)
+}
+
+func check(u model.URLStore) (model.URLStore, error) {
+	if u.DeletedFlag {
+		return model.URLStore{}, model.ErrDeleted
+	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
+
+	return u, nil
+}
@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepo returns a repository on an embedded Redis stand-in that
+// lives as long as the test.
+func newTestRepo(t *testing.T) *urlRepository {
+	t.Helper()
+
+	srv := miniredis.RunT(t)
+	repo, err := NewURLRepository(context.Background(), goredis.NewClient(&goredis.Options{Addr: srv.Addr()}))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository { return newTestRepo(t) })
+}
+
+func TestClicks(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repotest.ClickStore { return newTestRepo(t) })
+}
+
+func TestUsers(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) repotest.UserStore { return newTestRepo(t) })
+}
+
+func TestAPIKeys(t *testing.T) {
+	repotest.RunAPIKeys(t, func(t *testing.T) service.APIKeyRepository { return newTestRepo(t) })
+}
+
+func TestAdmin(t *testing.T) {
+	repotest.RunAdmin(t, func(t *testing.T) repotest.AdminStore { return newTestRepo(t) })
+}
+
+func TestSequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) service.SequenceRepository { return newTestRepo(t) })
+}
+
+func TestReplicasShareLinks(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+
+	replicas := make([]*urlRepository, 4)
+	for i := range replicas {
+		repo, err := NewURLRepository(ctx, goredis.NewClient(&goredis.Options{Addr: srv.Addr()}))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		replicas[i] = repo
+	}
+
+	// Every replica races to shorten the same URL: one wins and the
+	// others are told its code.
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		won    int
+		shorts = make(map[string]struct{})
+	)
+	for i, repo := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			short, err := repo.Save(ctx, model.URLStore{
+				UserID:   "u1",
+				Short:    fmt.Sprintf("code%d", i),
+				Original: "https://example.com/race",
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				won++
+			} else {
+				assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+			}
+			shorts[short] = struct{}{}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, won)
+	assert.Len(t, shorts, 1)
+
+	var winner string
+	for short := range shorts {
+		winner = short
+	}
+	for _, repo := range replicas {
+		u, err := repo.Get(ctx, winner)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/race", u.Original)
+	}
+
+	// UUIDs come from one counter.
+	_, err := replicas[1].Save(ctx, model.URLStore{UserID: "u1", Short: "next", Original: "https://example.com/next"})
+	require.NoError(t, err)
+	u, err := replicas[2].Get(ctx, "next")
+	require.NoError(t, err)
+	assert.Equal(t, 2, u.UUID)
+}
+
+func TestPurgeDropsClickCounters(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	repo, err := NewURLRepository(ctx, goredis.NewClient(&goredis.Options{Addr: srv.Addr()}))
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	soon := time.Now().Add(time.Minute)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com", ExpiresAt: &soon})
+	require.NoError(t, err)
+	day := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+		{Time: day, Short: "abc", IPHash: "ip1", Referrer: "https://a.example"},
+		{Time: day.Add(2 * time.Hour), Short: "abc", IPHash: "ip2", Country: "DE"},
+	}))
+	assert.Len(t, keysOf(srv, "clicks:", "visitors:"), 5)
+
+	_, err = repo.DeleteExpired(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, keysOf(srv, "clicks:", "visitors:"))
+}
+
+func keysOf(srv *miniredis.Miniredis, prefixes ...string) []string {
+	var res []string
+	for _, k := range srv.Keys() {
+		for _, p := range prefixes {
+			if strings.HasPrefix(k, keyPrefix+p) {
+				res = append(res, k)
+			}
+		}
+	}
+	return res
+}
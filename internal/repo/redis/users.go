@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Accounts are hashes under account:<id>, indexed by the emails hash.
+func (repo *urlRepository) CreateUser(ctx context.Context, u model.User) error {
+	ok, err := createUserScript.Run(ctx, repo.db,
+		[]string{repo.key("emails"), repo.key("account", u.ID)},
+		u.Email, u.ID,
+		"email", u.Email,
+		"password_hash", u.PasswordHash,
+		"role", u.Role,
+		"created_at", u.CreatedAt.Format(time.RFC3339Nano),
+	).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.CreateUser error: %w", err)
+	}
+	if !ok {
+		return model.ErrUserExists
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) UserByEmail(ctx context.Context, email string) (model.User, error) {
+	id, err := repo.db.HGet(ctx, repo.key("emails"), email).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, fmt.Errorf("redis.UserByEmail error: %w", err)
+	}
+
+	return repo.UserByID(ctx, id)
+}
+
+func (repo *urlRepository) UserByID(ctx context.Context, id string) (model.User, error) {
+	m, err := repo.db.HGetAll(ctx, repo.key("account", id)).Result()
+	if err != nil {
+		return model.User{}, fmt.Errorf("redis.UserByID error: %w", err)
+	}
+	if len(m) == 0 {
+		return model.User{}, model.ErrUserNotFound
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, m["created_at"])
+	if err != nil {
+		return model.User{}, fmt.Errorf("redis.UserByID error: created_at: %w", err)
+	}
+
+	return model.User{
+		ID:           id,
+		Email:        m["email"],
+		PasswordHash: m["password_hash"],
+		Role:         m["role"],
+		CreatedAt:    created,
+	}, nil
+}
+
+func (repo *urlRepository) SetRole(ctx context.Context, userID, role string) error {
+	ok, err := hsetIfExistsScript.Run(ctx, repo.db, []string{repo.key("account", userID)}, "role", role).Bool()
+	if err != nil {
+		return fmt.Errorf("redis.SetRole error: %w", err)
+	}
+	if !ok {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AdminStore is a backend as the admin service sees it.
+type AdminStore interface {
+	service.URLRepository
+	service.AdminRepository
+}
+
+// RunAdmin checks the moderation side of a backend. newRepo follows the
+// same rules as Constructor.
+func RunAdmin(t *testing.T, newRepo func(t *testing.T) AdminStore) {
+	t.Helper()
+
+	seed := func(t *testing.T, repo AdminStore) {
+		past := time.Now().Add(-time.Minute)
+		expired := url("u1", "expired", "https://example.com/expired")
+		expired.ExpiresAt = &past
+		require.NoError(t, repo.SaveAll(context.Background(), []model.URLStore{
+			url("u1", "one", "https://example.com/one"),
+			url("u1", "two", "https://example.com/two"),
+			expired,
+		}))
+		require.NoError(t, repo.DeleteBatch(context.Background(), "u1", []string{"two"}))
+	}
+
+	t.Run("FindURL", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		seed(t, repo)
+
+		u, err := repo.FindURL(ctx, "one", "")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/one", u.Original)
+
+		u, err = repo.FindURL(ctx, "", "https://example.com/two")
+		require.NoError(t, err)
+		assert.Equal(t, "two", u.Short)
+		assert.True(t, u.DeletedFlag, "deleted links are found")
+
+		u, err = repo.FindURL(ctx, "expired", "")
+		require.NoError(t, err)
+		assert.True(t, u.Expired(time.Now()), "expired links are found")
+
+		_, err = repo.FindURL(ctx, "missing", "")
+		assert.ErrorIs(t, err, model.ErrURLNotFound)
+		_, err = repo.FindURL(ctx, "", "https://example.com/missing")
+		assert.ErrorIs(t, err, model.ErrURLNotFound)
+	})
+
+	t.Run("URLsByUser", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		urls, err := repo.URLsByUser(context.Background(), "u1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"one", "two", "expired"}, shorts(urls))
+	})
+
+	t.Run("SetDeleted", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		seed(t, repo)
+
+		require.NoError(t, repo.SetDeleted(ctx, "one", true))
+		_, err := repo.Get(ctx, "one")
+		assert.ErrorIs(t, err, model.ErrDeleted)
+
+		require.NoError(t, repo.SetDeleted(ctx, "two", false))
+		_, err = repo.Get(ctx, "two")
+		require.NoError(t, err, "a deleted link can be restored")
+
+		assert.ErrorIs(t, repo.SetDeleted(ctx, "missing", true), model.ErrURLNotFound)
+	})
+
+	t.Run("ReassignURL", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		seed(t, repo)
+
+		require.NoError(t, repo.ReassignURL(ctx, "one", "u2"))
+		urls, err := repo.GetAllByUser(ctx, "u2")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"one"}, shorts(urls))
+		urls, err = repo.GetAllByUser(ctx, "u1")
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+
+		assert.ErrorIs(t, repo.ReassignURL(ctx, "missing", "u2"), model.ErrURLNotFound)
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, action := range []string{model.AuditLookup, model.AuditDisable, model.AuditTransfer} {
+			require.NoError(t, repo.SaveAudit(ctx, model.AuditEntry{
+				Actor:  "admin",
+				Action: action,
+				Target: "one",
+				Detail: "detail " + action,
+				At:     at.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+
+		entries, err := repo.AuditLog(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, model.AuditTransfer, entries[0].Action, "newest first")
+		assert.Equal(t, model.AuditDisable, entries[1].Action)
+		assert.Equal(t, "admin", entries[0].Actor)
+		assert.Equal(t, "one", entries[0].Target)
+		assert.Equal(t, "detail transfer", entries[0].Detail)
+		assert.True(t, at.Add(2*time.Minute).Equal(entries[0].At))
+		assert.Greater(t, entries[0].ID, entries[1].ID)
+	})
+}
@@ -0,0 +1,103 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunAPIKeys checks API key storage. newRepo follows the same rules as
+// Constructor.
+func RunAPIKeys(t *testing.T, newRepo func(t *testing.T) service.APIKeyRepository) {
+	t.Helper()
+
+	created := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := func(id, userID string, age time.Duration) model.APIKey {
+		return model.APIKey{
+			ID:        id,
+			UserID:    userID,
+			Name:      "key " + id,
+			Prefix:    "shk_" + id,
+			Hash:      "hash-" + id,
+			Scopes:    []string{model.ScopeRead, model.ScopeShorten},
+			CreatedAt: created.Add(-age),
+		}
+	}
+
+	t.Run("CreateAndFind", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		exp := created.Add(time.Hour)
+		want := key("k1", "u1", 0)
+		want.ExpiresAt = &exp
+		require.NoError(t, repo.CreateAPIKey(ctx, want))
+
+		got, err := repo.APIKeyByHash(ctx, "hash-k1")
+		require.NoError(t, err)
+		assert.Equal(t, "k1", got.ID)
+		assert.Equal(t, "u1", got.UserID)
+		assert.Equal(t, want.Scopes, got.Scopes)
+		require.NotNil(t, got.ExpiresAt)
+		assert.True(t, exp.Equal(*got.ExpiresAt))
+		assert.Nil(t, got.LastUsedAt)
+
+		_, err = repo.APIKeyByHash(ctx, "hash-missing")
+		assert.ErrorIs(t, err, model.ErrAPIKeyNotFound)
+	})
+
+	t.Run("ListByUser", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateAPIKey(ctx, key("new", "u1", 0)))
+		require.NoError(t, repo.CreateAPIKey(ctx, key("old", "u1", time.Hour)))
+		require.NoError(t, repo.CreateAPIKey(ctx, key("other", "u2", 0)))
+
+		keys, err := repo.APIKeysByUser(ctx, "u1")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "old", keys[0].ID, "oldest first")
+		assert.Equal(t, "new", keys[1].ID)
+
+		keys, err = repo.APIKeysByUser(ctx, "nobody")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateAPIKey(ctx, key("k1", "u1", 0)))
+
+		assert.ErrorIs(t, repo.DeleteAPIKey(ctx, "u2", "k1"), model.ErrAPIKeyNotFound, "only the owner deletes")
+		require.NoError(t, repo.DeleteAPIKey(ctx, "u1", "k1"))
+		assert.ErrorIs(t, repo.DeleteAPIKey(ctx, "u1", "k1"), model.ErrAPIKeyNotFound)
+
+		_, err := repo.APIKeyByHash(ctx, "hash-k1")
+		assert.ErrorIs(t, err, model.ErrAPIKeyNotFound)
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateAPIKey(ctx, key("k1", "u1", 0)))
+		used := created.Add(time.Minute)
+		require.NoError(t, repo.TouchAPIKey(ctx, "k1", used))
+
+		got, err := repo.APIKeyByHash(ctx, "hash-k1")
+		require.NoError(t, err)
+		require.NotNil(t, got.LastUsedAt)
+		assert.True(t, used.Equal(*got.LastUsedAt))
+
+		assert.ErrorIs(t, repo.TouchAPIKey(ctx, "missing", used), model.ErrAPIKeyNotFound)
+	})
+}
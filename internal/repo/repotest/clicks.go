@@ -0,0 +1,142 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ClickStore is the part of a backend that records and aggregates
+// clicks, along with the links they belong to.
+type ClickStore interface {
+	service.URLRepository
+	SaveClicks(context.Context, []model.Click) error
+	ClickStats(context.Context, string, model.StatsFilter) (model.LinkStats, error)
+}
+
+// RunClicks checks that clicks saved by a backend come back in its
+// statistics. newRepo follows the same rules as Constructor.
+func RunClicks(t *testing.T, newRepo func(t *testing.T) ClickStore) {
+	t.Helper()
+
+	t.Run("ClickStats", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		day := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+
+		click := func(short string, at time.Duration, ip, ref, country, ua string) model.Click {
+			return model.Click{
+				Time:      day.Add(at),
+				Short:     short,
+				IPHash:    ip,
+				Referrer:  ref,
+				Country:   country,
+				UserAgent: ua,
+			}
+		}
+		require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+			click("abc", time.Hour, "ip1", "https://a.example", "DE", "curl"),
+			click("abc", time.Hour+time.Minute, "ip1", "https://a.example", "DE", "firefox"),
+			click("abc", 3*time.Hour, "ip2", "https://b.example", "FR", "firefox"),
+			click("abc", 26*time.Hour, "ip3", "", "", "firefox"),
+			click("abc", 50*time.Hour, "ip4", "https://c.example", "US", "curl"),
+			click("other", time.Hour, "ip1", "https://a.example", "DE", "curl"),
+		}))
+
+		res, err := repo.ClickStats(ctx, "abc", model.StatsFilter{
+			From:   day,
+			To:     day.Add(48 * time.Hour),
+			Bucket: model.BucketDay,
+			Top:    2,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, res.TotalClicks)
+		assert.Equal(t, 3, res.UniqueVisitors)
+
+		require.Len(t, res.Series, 2)
+		assert.True(t, day.Equal(res.Series[0].Time))
+		assert.Equal(t, 3, res.Series[0].Clicks)
+		assert.Equal(t, 2, res.Series[0].UniqueVisitors)
+		assert.True(t, day.Add(24*time.Hour).Equal(res.Series[1].Time))
+		assert.Equal(t, 1, res.Series[1].Clicks)
+
+		assert.Equal(t, []model.StatsEntry{
+			{Value: "https://a.example", Count: 2},
+			{Value: "https://b.example", Count: 1},
+		}, res.TopReferrers)
+		assert.Equal(t, []model.StatsEntry{
+			{Value: "DE", Count: 2},
+			{Value: "FR", Count: 1},
+		}, res.TopCountries)
+		assert.Equal(t, []model.StatsEntry{
+			{Value: "firefox", Count: 3},
+			{Value: "curl", Count: 1},
+		}, res.TopUserAgents)
+
+		hourly, err := repo.ClickStats(ctx, "abc", model.StatsFilter{
+			From:   day,
+			To:     day.Add(24 * time.Hour),
+			Bucket: model.BucketHour,
+			Top:    10,
+		})
+		require.NoError(t, err)
+		require.Len(t, hourly.Series, 2)
+		assert.Equal(t, 2, hourly.Series[0].Clicks)
+		assert.Equal(t, 1, hourly.Series[0].UniqueVisitors)
+
+		empty, err := repo.ClickStats(ctx, "missing", model.StatsFilter{
+			From:   day,
+			To:     day.Add(24 * time.Hour),
+			Bucket: model.BucketDay,
+			Top:    10,
+		})
+		require.NoError(t, err)
+		assert.Zero(t, empty.TotalClicks)
+		assert.Empty(t, empty.Series)
+		assert.Empty(t, empty.TopReferrers)
+	})
+	t.Run("ReusedShort", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+		all := model.StatsFilter{
+			From:   time.Now().Add(-time.Hour),
+			To:     time.Now().Add(time.Hour),
+			Bucket: model.BucketDay,
+			Top:    10,
+		}
+
+		soon := time.Now().Add(time.Minute)
+		reaped := url("user1", "reaped", "https://example.com/reaped")
+		reaped.ExpiresAt = &soon
+		past := time.Now().Add(-time.Minute)
+		taken := url("user1", "taken", "https://example.com/taken")
+		taken.ExpiresAt = &past
+		require.NoError(t, repo.SaveAll(ctx, []model.URLStore{reaped, taken}))
+		require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+			{Time: time.Now(), Short: "reaped", IPHash: "ip1"},
+			{Time: time.Now(), Short: "taken", IPHash: "ip1"},
+		}))
+
+		// The reaper purges one link, a new link takes over the other.
+		_, err := repo.DeleteExpired(ctx, time.Now().Add(2*time.Minute))
+		require.NoError(t, err)
+		_, err = repo.Save(ctx, url("user2", "reaped", "https://example.com/new-reaped"))
+		require.NoError(t, err)
+		_, err = repo.Save(ctx, url("user2", "taken", "https://example.com/new-taken"))
+		require.NoError(t, err)
+
+		for _, short := range []string{"reaped", "taken"} {
+			res, err := repo.ClickStats(ctx, short, all)
+			require.NoError(t, err)
+			assert.Zero(t, res.TotalClicks, short)
+			assert.Zero(t, res.UniqueVisitors, short)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package repotest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunSequence checks the sequence behind the counter code strategy.
+// newRepo follows the same rules as Constructor.
+func RunSequence(t *testing.T, newRepo func(t *testing.T) service.SequenceRepository) {
+	t.Helper()
+
+	t.Run("Starts at one", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for want := int64(1); want <= 3; want++ {
+			n, err := repo.NextSequence(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, want, n)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const workers, each = 8, 50
+		var (
+			mu   sync.Mutex
+			seen = make(map[int64]struct{}, workers*each)
+			wg   sync.WaitGroup
+		)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range each {
+					n, err := repo.NextSequence(context.Background())
+					if !assert.NoError(t, err) {
+						return
+					}
+					mu.Lock()
+					seen[n] = struct{}{}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, seen, workers*each, "a number was handed out twice")
+	})
+}
@@ -0,0 +1,387 @@
+// Package repotest holds the conformance suite every
+// service.URLRepository backend is expected to pass.
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Constructor returns an empty repository. It is called once per
+// subtest and is responsible for registering its own cleanup.
+type Constructor func(t *testing.T) service.URLRepository
+
+// Run checks the whole URLRepository contract against the backend
+// built by newRepo.
+func Run(t *testing.T, newRepo Constructor) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo service.URLRepository)
+	}{
+		{"Ping", testPing},
+		{"SaveAndGet", testSaveAndGet},
+		{"NotFound", testNotFound},
+		{"DuplicateOriginal", testDuplicateOriginal},
+		{"ShortCollision", testShortCollision},
+		{"SaveAll", testSaveAll},
+		{"SaveAllAtomic", testSaveAllAtomic},
+		{"SaveAllDuplicateInBatch", testSaveAllDuplicateInBatch},
+		{"UserIsolation", testUserIsolation},
+		{"DeleteBatch", testDeleteBatch},
+		{"DeleteBatchForeignOwner", testDeleteBatchForeignOwner},
+		{"Expiry", testExpiry},
+		{"DeleteExpired", testDeleteExpired},
+		{"Stats", testStats},
+		{"ReassignUser", testReassignUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func testPing(t *testing.T, repo service.URLRepository) {
+	require.NoError(t, repo.Ping(context.Background()))
+}
+
+func testSaveAndGet(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	short, err := repo.Save(ctx, url("user1", "abc123", "https://example.com/a"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", short)
+
+	u, err := repo.Get(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", u.Short)
+	assert.Equal(t, "https://example.com/a", u.Original)
+	assert.NotZero(t, u.UUID)
+
+	byID, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", byID.Short)
+	assert.Equal(t, "https://example.com/a", byID.Original)
+	assert.Equal(t, "user1", byID.UserID)
+}
+
+func testNotFound(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Get(ctx, "missing")
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+
+	_, err = repo.GetByID(ctx, 100500)
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+
+	urls, err := repo.GetAllByUser(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testDuplicateOriginal(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, url("user1", "first", "https://example.com/dup"))
+	require.NoError(t, err)
+
+	short, err := repo.Save(ctx, url("user2", "second", "https://example.com/dup"))
+	assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+	assert.Equal(t, "first", short)
+
+	_, err = repo.Get(ctx, "second")
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+}
+
+func testShortCollision(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, url("user1", "clash", "https://example.com/one"))
+	require.NoError(t, err)
+
+	_, err = repo.Save(ctx, url("user2", "clash", "https://example.com/two"))
+	assert.ErrorIs(t, err, model.ErrShortExists)
+
+	err = repo.SaveAll(ctx, []model.URLStore{
+		url("user2", "other", "https://example.com/three"),
+		url("user2", "clash", "https://example.com/four"),
+	})
+	assert.ErrorIs(t, err, model.ErrShortExists)
+
+	u, err := repo.Get(ctx, "clash")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/one", u.Original)
+
+	_, err = repo.Get(ctx, "other")
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+}
+
+func testSaveAll(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		url("user1", "batch1", "https://example.com/b1"),
+		url("user1", "batch2", "https://example.com/b2"),
+		url("user1", "batch3", "https://example.com/b3"),
+	}))
+
+	for _, short := range []string{"batch1", "batch2", "batch3"} {
+		u, err := repo.Get(ctx, short)
+		require.NoError(t, err)
+		assert.Equal(t, short, u.Short)
+	}
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"batch1", "batch2", "batch3"}, shorts(urls))
+}
+
+func testSaveAllAtomic(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, url("user1", "taken", "https://example.com/taken"))
+	require.NoError(t, err)
+
+	err = repo.SaveAll(ctx, []model.URLStore{
+		url("user1", "fresh1", "https://example.com/fresh1"),
+		url("user1", "fresh2", "https://example.com/taken"),
+	})
+	assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+
+	_, err = repo.Get(ctx, "fresh1")
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"taken"}, shorts(urls))
+}
+
+func testSaveAllDuplicateInBatch(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	err := repo.SaveAll(ctx, []model.URLStore{
+		url("user1", "same1", "https://example.com/same"),
+		url("user1", "same2", "https://example.com/same"),
+	})
+	assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testUserIsolation(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, url("alice", "alice1", "https://example.com/alice1"))
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		url("bob", "bob1", "https://example.com/bob1"),
+		url("bob", "bob2", "https://example.com/bob2"),
+	}))
+
+	alice, err := repo.GetAllByUser(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice1"}, shorts(alice))
+
+	bob, err := repo.GetAllByUser(ctx, "bob")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bob1", "bob2"}, shorts(bob))
+}
+
+func testDeleteBatch(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		url("user1", "keep", "https://example.com/keep"),
+		url("user1", "drop", "https://example.com/drop"),
+	}))
+	dropped, err := repo.Get(ctx, "drop")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteBatch(ctx, "user1", []string{"drop", "missing"}))
+
+	_, err = repo.Get(ctx, "drop")
+	assert.ErrorIs(t, err, model.ErrDeleted)
+
+	_, err = repo.GetByID(ctx, dropped.UUID)
+	assert.ErrorIs(t, err, model.ErrDeleted)
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"keep"}, shorts(urls))
+
+	// Deleting twice is not an error.
+	require.NoError(t, repo.DeleteBatch(ctx, "user1", []string{"drop"}))
+
+	// A deleted record still owns its original URL.
+	short, err := repo.Save(ctx, url("user1", "again", "https://example.com/drop"))
+	assert.ErrorIs(t, err, model.ErrURLAlreadyExists)
+	assert.Equal(t, "drop", short)
+}
+
+func testDeleteBatchForeignOwner(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, url("owner", "mine", "https://example.com/mine"))
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteBatch(ctx, "intruder", []string{"mine"}))
+
+	u, err := repo.Get(ctx, "mine")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/mine", u.Original)
+}
+
+func testExpiry(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	live := url("user1", "live", "https://example.com/live")
+	live.ExpiresAt = &future
+	_, err := repo.Save(ctx, live)
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	gone := url("user1", "gone", "https://example.com/gone")
+	gone.ExpiresAt = &past
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{gone}))
+
+	u, err := repo.Get(ctx, "live")
+	require.NoError(t, err)
+	require.NotNil(t, u.ExpiresAt)
+	assert.True(t, future.Equal(*u.ExpiresAt))
+
+	_, err = repo.Get(ctx, "gone")
+	assert.ErrorIs(t, err, model.ErrExpired)
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"live"}, shorts(urls))
+
+	// An expired record gives up its original URL and short code.
+	_, err = repo.Save(ctx, url("user2", "gone", "https://example.com/gone"))
+	require.NoError(t, err)
+
+	u, err = repo.Get(ctx, "gone")
+	require.NoError(t, err)
+	assert.Nil(t, u.ExpiresAt)
+
+	byID, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "user2", byID.UserID)
+}
+
+func testDeleteExpired(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	soon := time.Now().Add(time.Minute)
+	later := time.Now().Add(time.Hour)
+	first := url("user1", "first", "https://example.com/first")
+	first.ExpiresAt = &soon
+	second := url("user1", "second", "https://example.com/second")
+	second.ExpiresAt = &later
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		first,
+		second,
+		url("user1", "forever", "https://example.com/forever"),
+	}))
+
+	n, err := repo.DeleteExpired(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = repo.Get(ctx, "first")
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+
+	urls, err := repo.GetAllByUser(ctx, "user1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"second", "forever"}, shorts(urls))
+}
+
+func testStats(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	st, err := repo.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, model.ServiceStats{}, st)
+
+	past := time.Now().Add(-time.Minute)
+	expired := url("user2", "expired", "https://example.com/expired")
+	expired.ExpiresAt = &past
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		url("user1", "one", "https://example.com/one"),
+		url("user1", "two", "https://example.com/two"),
+		url("user2", "three", "https://example.com/three"),
+		expired,
+	}))
+	require.NoError(t, repo.DeleteBatch(ctx, "user1", []string{"two"}))
+
+	st, err = repo.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, model.ServiceStats{URLs: 4, Users: 2, Live: 2, Deleted: 1, Expired: 1}, st)
+}
+
+func testReassignUser(t *testing.T, repo service.URLRepository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		url("anon", "one", "https://example.com/one"),
+		url("anon", "two", "https://example.com/two"),
+		url("account", "three", "https://example.com/three"),
+		url("other", "four", "https://example.com/four"),
+	}))
+	require.NoError(t, repo.DeleteBatch(ctx, "anon", []string{"two"}))
+
+	n, err := repo.ReassignUser(ctx, "anon", "account")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	urls, err := repo.GetAllByUser(ctx, "account")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"one", "three"}, shorts(urls))
+
+	urls, err = repo.GetAllByUser(ctx, "anon")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	u, err := repo.Get(ctx, "one")
+	require.NoError(t, err)
+	assert.Equal(t, "account", u.UserID)
+	_, err = repo.Get(ctx, "two")
+	assert.ErrorIs(t, err, model.ErrDeleted, "deleted links stay deleted")
+
+	// The new owner can delete the moved links, the old one cannot.
+	require.NoError(t, repo.DeleteBatch(ctx, "anon", []string{"one"}))
+	_, err = repo.Get(ctx, "one")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteBatch(ctx, "account", []string{"one"}))
+	_, err = repo.Get(ctx, "one")
+	assert.ErrorIs(t, err, model.ErrDeleted)
+
+	n, err = repo.ReassignUser(ctx, "nobody", "account")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func url(userID, short, original string) model.URLStore {
+	return model.URLStore{UserID: userID, Short: short, Original: original}
+}
+
+func shorts(urls []model.URLStore) []string {
+	res := make([]string, 0, len(urls))
+	for _, u := range urls {
+		res = append(res, u.Short)
+	}
+	return res
+}
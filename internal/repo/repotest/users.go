@@ -0,0 +1,81 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UserStore is the part of a backend that keeps registered accounts.
+type UserStore interface {
+	CreateUser(context.Context, model.User) error
+	UserByEmail(context.Context, string) (model.User, error)
+	UserByID(context.Context, string) (model.User, error)
+	SetRole(ctx context.Context, userID, role string) error
+}
+
+// RunUsers checks account storage. newRepo follows the same rules as
+// Constructor.
+func RunUsers(t *testing.T, newRepo func(t *testing.T) UserStore) {
+	t.Helper()
+
+	t.Run("CreateAndFind", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		created := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		want := model.User{ID: "u1", Email: "ann@example.com", PasswordHash: "hash", CreatedAt: created}
+		require.NoError(t, repo.CreateUser(ctx, want))
+
+		got, err := repo.UserByEmail(ctx, "ann@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, want.ID, got.ID)
+		assert.Equal(t, want.PasswordHash, got.PasswordHash)
+		assert.True(t, created.Equal(got.CreatedAt))
+
+		got, err = repo.UserByID(ctx, "u1")
+		require.NoError(t, err)
+		assert.Equal(t, want.Email, got.Email)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		_, err := repo.UserByEmail(ctx, "nobody@example.com")
+		assert.ErrorIs(t, err, model.ErrUserNotFound)
+		_, err = repo.UserByID(ctx, "nobody")
+		assert.ErrorIs(t, err, model.ErrUserNotFound)
+	})
+
+	t.Run("SetRole", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateUser(ctx, model.User{ID: "u1", Email: "ann@example.com", PasswordHash: "hash", CreatedAt: time.Now()}))
+		require.NoError(t, repo.SetRole(ctx, "u1", model.RoleAdmin))
+
+		got, err := repo.UserByEmail(ctx, "ann@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, got.Role)
+
+		assert.ErrorIs(t, repo.SetRole(ctx, "nobody", model.RoleAdmin), model.ErrUserNotFound)
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateUser(ctx, model.User{ID: "u1", Email: "ann@example.com", PasswordHash: "a", CreatedAt: time.Now()}))
+		err := repo.CreateUser(ctx, model.User{ID: "u2", Email: "ann@example.com", PasswordHash: "b", CreatedAt: time.Now()})
+		assert.ErrorIs(t, err, model.ErrUserExists)
+
+		_, err = repo.UserByID(ctx, "u2")
+		assert.ErrorIs(t, err, model.ErrUserNotFound)
+	})
+}
@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) FindURL(ctx context.Context, short, original string) (model.URLStore, error) {
+	column, value := "short_url", short
+	if short == "" {
+		column, value = "original_url", original
+	}
+
+	u, err := scanURL(repo.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT `+urlColumns+` FROM urls WHERE %s = $1`, column),
+		value,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		return model.URLStore{}, fmt.Errorf("sqlite.FindURL error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) URLsByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	res, err := repo.queryURLs(ctx,
+		`SELECT `+urlColumns+` FROM urls WHERE user_id = $1 ORDER BY uuid`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.URLsByUser error: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) SetDeleted(ctx context.Context, short string, deleted bool) error {
+	res, err := repo.db.ExecContext(ctx,
+		`UPDATE urls SET is_deleted = $2 WHERE short_url = $1`,
+		short, deleted,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite.SetDeleted error: %w", err)
+	}
+
+	return affected(res, model.ErrURLNotFound)
+}
+
+func (repo *urlRepository) ReassignURL(ctx context.Context, short, to string) error {
+	res, err := repo.db.ExecContext(ctx,
+		`UPDATE urls SET user_id = $2 WHERE short_url = $1`,
+		short, to,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite.ReassignURL error: %w", err)
+	}
+
+	return affected(res, model.ErrURLNotFound)
+}
+
+func (repo *urlRepository) SaveAudit(ctx context.Context, e model.AuditEntry) error {
+	if _, err := repo.db.ExecContext(ctx,
+		`INSERT INTO audit_log (actor, action, target, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		e.Actor, e.Action, e.Target, e.Detail, e.At.UTC(),
+	); err != nil {
+		return fmt.Errorf("sqlite.SaveAudit error: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) AuditLog(ctx context.Context, limit int) ([]model.AuditEntry, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		`SELECT id, actor, action, target, detail, created_at
+		FROM audit_log
+		ORDER BY id DESC
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.AuditLog error: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]model.AuditEntry, 0)
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.Detail, &e.At); err != nil {
+			return nil, fmt.Errorf("sqlite.AuditLog error: failed to scan a row: %w", err)
+		}
+		res = append(res, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite.AuditLog error: while reading: %w", err)
+	}
+
+	return res, nil
+}
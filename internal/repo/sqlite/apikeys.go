@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at`
+
+func (repo *urlRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	if _, err := repo.db.ExecContext(ctx,
+		`INSERT INTO api_keys (`+apiKeyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.Hash, strings.Join(k.Scopes, ","),
+		k.CreatedAt.UTC(), utc(k.ExpiresAt), utc(k.LastUsedAt),
+	); err != nil {
+		return fmt.Errorf("sqlite.CreateAPIKey error: insert: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) APIKeysByUser(ctx context.Context, userID string) ([]model.APIKey, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.APIKeysByUser error: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]model.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite.APIKeysByUser error: failed to scan a row: %w", err)
+		}
+		res = append(res, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite.APIKeysByUser error: while reading: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) APIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	k, err := scanAPIKey(repo.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`,
+		hash,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.APIKey{}, model.ErrAPIKeyNotFound
+		}
+		return model.APIKey{}, fmt.Errorf("sqlite.APIKeyByHash error: %w", err)
+	}
+
+	return k, nil
+}
+
+func (repo *urlRepository) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	res, err := repo.db.ExecContext(ctx,
+		`DELETE FROM api_keys WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite.DeleteAPIKey error: %w", err)
+	}
+
+	return affected(res, model.ErrAPIKeyNotFound)
+}
+
+func (repo *urlRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	res, err := repo.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`,
+		id, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite.TouchAPIKey error: %w", err)
+	}
+
+	return affected(res, model.ErrAPIKeyNotFound)
+}
+
+func scanAPIKey(row scanner) (model.APIKey, error) {
+	var (
+		k      model.APIKey
+		scopes string
+	)
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Hash, &scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt); err != nil {
+		return model.APIKey{}, err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	if scopes == "" {
+		k.Scopes = []string{}
+	}
+
+	return k, nil
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite.SaveClicks error: start a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash, accept_language, country)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlite.SaveClicks error: prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		if _, err := stmt.ExecContext(ctx,
+			c.Short, c.Time.UTC(), c.Referrer, c.UserAgent, c.IPHash, c.AcceptLanguage, c.Country,
+		); err != nil {
+			return fmt.Errorf("sqlite.SaveClicks error: insert: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite.SaveClicks error: failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// topColumns are the clicks columns ranked in LinkStats.
+var topColumns = []string{"referrer", "country", "user_agent"}
+
+// bucketFormats truncate a click time to the start of its bucket with
+// strftime, rendered in bucketLayout.
+var bucketFormats = map[string]string{
+	model.BucketHour: "%Y-%m-%d %H:00:00",
+	model.BucketDay:  "%Y-%m-%d 00:00:00",
+}
+
+const bucketLayout = "2006-01-02 15:04:05"
+
+func (repo *urlRepository) ClickStats(ctx context.Context, short string, f model.StatsFilter) (model.LinkStats, error) {
+	res := model.LinkStats{Short: short, From: f.From, To: f.To, Bucket: f.Bucket}
+	from, to := f.From.UTC(), f.To.UTC()
+
+	if err := repo.db.QueryRowContext(ctx,
+		`SELECT count(*), count(DISTINCT NULLIF(ip_hash, ''))
+		FROM clicks
+		WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3`,
+		short, from, to,
+	).Scan(&res.TotalClicks, &res.UniqueVisitors); err != nil {
+		return model.LinkStats{}, fmt.Errorf("sqlite.ClickStats error: totals: %w", err)
+	}
+
+	series, err := repo.series(ctx, short, from, to, bucketFormats[f.Bucket])
+	if err != nil {
+		return model.LinkStats{}, fmt.Errorf("sqlite.ClickStats error: series: %w", err)
+	}
+	res.Series = series
+
+	tops := []*[]model.StatsEntry{&res.TopReferrers, &res.TopCountries, &res.TopUserAgents}
+	for i, col := range topColumns {
+		top, err := repo.top(ctx, short, from, to, col, f.Top)
+		if err != nil {
+			return model.LinkStats{}, fmt.Errorf("sqlite.ClickStats error: top %s: %w", col, err)
+		}
+		*tops[i] = top
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) series(ctx context.Context, short string, from, to time.Time, format string) ([]model.StatsPoint, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		`SELECT strftime($4, clicked_at) AS bucket,
+			count(*), count(DISTINCT NULLIF(ip_hash, ''))
+		FROM clicks
+		WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3
+		GROUP BY bucket
+		ORDER BY bucket`,
+		short, from, to, format,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.StatsPoint, 0)
+	for rows.Next() {
+		var (
+			p      model.StatsPoint
+			bucket string
+		)
+		if err := rows.Scan(&bucket, &p.Clicks, &p.UniqueVisitors); err != nil {
+			return nil, err
+		}
+		if p.Time, err = time.Parse(bucketLayout, bucket); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+
+	return res, rows.Err()
+}
+
+func (repo *urlRepository) top(ctx context.Context, short string, from, to time.Time, col string, limit int) ([]model.StatsEntry, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT %[1]s, count(*) AS n
+		FROM clicks
+		WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3 AND %[1]s <> ''
+		GROUP BY %[1]s
+		ORDER BY n DESC, %[1]s
+		LIMIT $4`, col),
+		short, from, to, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.StatsEntry, 0, limit)
+	for rows.Next() {
+		var e model.StatsEntry
+		if err := rows.Scan(&e.Value, &e.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+
+	"shortener/internal/shared/database/migrate"
+)
+
+// The migrations mirror those of the pg package version for version, in
+// the SQLite dialect.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// NewMigrator needs no lock: SQLite itself serializes writers, and the
+// database belongs to a single node.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(db, sub)
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS urls (
+	uuid INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	user_id VARCHAR(50) NOT NULL,
+	short_url VARCHAR(10) NOT NULL,
+	original_url VARCHAR NOT NULL UNIQUE,
+	is_deleted BOOLEAN NOT NULL DEFAULT false
+);
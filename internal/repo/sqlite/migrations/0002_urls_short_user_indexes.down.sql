@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS urls_user_id_idx;
+DROP INDEX IF EXISTS urls_short_url_key;
@@ -0,0 +1,16 @@
+-- SQLite does not enforce VARCHAR lengths, so unlike in Postgres the
+-- short_url column needs no change.
+
+-- As in Postgres, duplicated codes other than the oldest are renamed to
+-- <code>-<uuid> before the index is built.
+UPDATE urls SET short_url = short_url || '-' || uuid
+WHERE uuid IN (
+	SELECT uuid FROM (
+		SELECT uuid, row_number() OVER (PARTITION BY short_url ORDER BY uuid) AS n
+		FROM urls
+	) AS dup
+	WHERE n > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS urls_short_url_key ON urls (short_url);
+CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id);
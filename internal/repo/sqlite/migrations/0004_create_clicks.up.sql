@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS clicks (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	short_url VARCHAR(64) NOT NULL,
+	clicked_at TIMESTAMP NOT NULL,
+	referrer VARCHAR NOT NULL DEFAULT '',
+	user_agent VARCHAR NOT NULL DEFAULT '',
+	ip_hash VARCHAR(64) NOT NULL DEFAULT '',
+	accept_language VARCHAR NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS clicks_short_url_clicked_at_idx ON clicks (short_url, clicked_at);
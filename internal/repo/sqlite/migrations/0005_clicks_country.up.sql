@@ -0,0 +1 @@
+ALTER TABLE clicks ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR(50) NOT NULL PRIMARY KEY,
+	email VARCHAR(254) NOT NULL,
+	password_hash VARCHAR NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT users_email_key UNIQUE (email)
+);
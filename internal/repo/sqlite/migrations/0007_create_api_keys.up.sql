@@ -0,0 +1,14 @@
+-- scopes is a comma-separated list, SQLite has no arrays.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	user_id VARCHAR(50) NOT NULL,
+	name VARCHAR(64) NOT NULL DEFAULT '',
+	prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
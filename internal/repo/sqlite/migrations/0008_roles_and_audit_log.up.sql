@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	actor VARCHAR(50) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	target VARCHAR NOT NULL DEFAULT '',
+	detail VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
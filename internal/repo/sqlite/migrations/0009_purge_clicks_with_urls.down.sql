@@ -0,0 +1 @@
+DROP TRIGGER IF EXISTS urls_purge_clicks;
@@ -0,0 +1,6 @@
+CREATE TRIGGER IF NOT EXISTS urls_purge_clicks AFTER DELETE ON urls
+BEGIN
+	DELETE FROM clicks WHERE short_url = OLD.short_url;
+END;
+
+DELETE FROM clicks WHERE short_url NOT IN (SELECT short_url FROM urls);
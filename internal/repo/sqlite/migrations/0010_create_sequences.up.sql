@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS sequences (
+	name VARCHAR(32) NOT NULL PRIMARY KEY,
+	value INTEGER NOT NULL
+);
@@ -0,0 +1,20 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// SQLite has no sequences; a row of the sequences table stands in.
+func (repo *urlRepository) NextSequence(ctx context.Context) (int64, error) {
+	var n int64
+	if err := repo.db.QueryRowContext(ctx,
+		`INSERT INTO sequences (name, value) VALUES ('link_codes', 1)
+		ON CONFLICT (name) DO UPDATE SET value = value + 1
+		RETURNING value`,
+	).Scan(&n); err != nil {
+		return 0, fmt.Errorf("sqlite.NextSequence error: %w", err)
+	}
+
+	return n, nil
+}
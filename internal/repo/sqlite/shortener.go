@@ -0,0 +1,324 @@
+// Package sqlite keeps links in an embedded SQLite database for
+// single-node installs. The driver is pure Go, so no cgo is needed.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+
+	sqlitedrv "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const urlColumns = `uuid, user_id, short_url, original_url, expires_at, is_deleted`
+
+type urlRepository struct {
+	db *sql.DB
+}
+
+func NewURLRepository(ctx context.Context, db *sql.DB) (*urlRepository, error) {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.NewURLRepository error: load migrations: %w", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		return nil, fmt.Errorf("sqlite.NewURLRepository error: %w", err)
+	}
+
+	return &urlRepository{db: db}, nil
+}
+
+// Close closes the database.
+func (repo *urlRepository) Close() error { return repo.db.Close() }
+
+func (repo *urlRepository) Ping(ctx context.Context) error { return repo.db.PingContext(ctx) }
+
+func (repo *urlRepository) Save(ctx context.Context, u model.URLStore) (string, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("sqlite.Save error: start a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Expired rows that have not been reaped yet must not block the
+	// original URL or the short code from being taken again.
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM urls
+		WHERE (original_url = $1 OR short_url = $2) AND expires_at <= $3`,
+		u.Original, u.Short, now(),
+	); err != nil {
+		return "", fmt.Errorf("sqlite.Save error: purge expired: %w", err)
+	}
+
+	if err := insert(ctx, tx, u); err != nil {
+		if isShortViolation(err) {
+			return "", model.ErrShortExists
+		}
+		if isUniqueViolation(err) {
+			var shortURL string
+			if err := tx.QueryRowContext(ctx,
+				"SELECT short_url FROM urls WHERE original_url = $1",
+				u.Original,
+			).Scan(&shortURL); err != nil {
+				return "", fmt.Errorf("sqlite.Save error: execute select query: %w", err)
+			}
+			return shortURL, model.ErrURLAlreadyExists
+		}
+		return "", fmt.Errorf("sqlite.Save error: execute query: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("sqlite.Save error: failed to commit: %w", err)
+	}
+
+	return u.Short, nil
+}
+
+func (repo *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite.SaveAll error: start a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	originals := make([]string, len(urls))
+	shorts := make([]string, len(urls))
+	for i, u := range urls {
+		originals[i], shorts[i] = u.Original, u.Short
+	}
+	args := []any{now()}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM urls
+		WHERE expires_at <= $1
+			AND (original_url IN (`+placeholders(&args, originals)+`)
+				OR short_url IN (`+placeholders(&args, shorts)+`))`,
+		args...,
+	); err != nil {
+		return fmt.Errorf("sqlite.SaveAll error: purge expired: %w", err)
+	}
+
+	for _, u := range urls {
+		if err := insert(ctx, tx, u); err != nil {
+			if isShortViolation(err) {
+				return model.ErrShortExists
+			}
+			if isUniqueViolation(err) {
+				return model.ErrURLAlreadyExists
+			}
+			return fmt.Errorf("sqlite.SaveAll error: insert: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite.SaveAll error: failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) Get(ctx context.Context, short string) (model.URLStore, error) {
+	u, err := scanURL(repo.db.QueryRowContext(ctx,
+		`SELECT `+urlColumns+` FROM urls WHERE short_url = $1`,
+		short,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		return model.URLStore{}, fmt.Errorf("sqlite.Get error: failed to find a row: %w", err)
+	}
+
+	return check(u)
+}
+
+func (repo *urlRepository) GetByID(ctx context.Context, uuid int) (model.URLStore, error) {
+	u, err := scanURL(repo.db.QueryRowContext(ctx,
+		`SELECT `+urlColumns+` FROM urls WHERE uuid = $1`,
+		uuid,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.URLStore{}, model.ErrURLNotFound
+		}
+		return model.URLStore{}, fmt.Errorf("sqlite.GetByID error: failed to find a row: %w", err)
+	}
+
+	return check(u)
+}
+
+func (repo *urlRepository) GetAllByUser(ctx context.Context, userID string) ([]model.URLStore, error) {
+	res, err := repo.queryURLs(ctx,
+		`SELECT `+urlColumns+`
+		FROM urls
+		WHERE user_id = $1 AND NOT is_deleted
+			AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY uuid`,
+		userID, now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.GetAllByUser error: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	args := []any{userID}
+	if _, err := repo.db.ExecContext(ctx,
+		`UPDATE urls SET is_deleted = true
+		WHERE user_id = $1 AND short_url IN (`+placeholders(&args, urls)+`)`,
+		args...,
+	); err != nil {
+		return fmt.Errorf("sqlite.DeleteBatch error: delete: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	res, err := repo.db.ExecContext(ctx,
+		`DELETE FROM urls WHERE expires_at <= $1`,
+		before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite.DeleteExpired error: delete: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite.DeleteExpired error: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (repo *urlRepository) Stats(ctx context.Context) (model.ServiceStats, error) {
+	var res model.ServiceStats
+	if err := repo.db.QueryRowContext(ctx,
+		`SELECT count(*),
+			count(DISTINCT user_id),
+			count(*) FILTER (WHERE NOT is_deleted AND (expires_at IS NULL OR expires_at > $1)),
+			count(*) FILTER (WHERE is_deleted),
+			count(*) FILTER (WHERE NOT is_deleted AND expires_at <= $1)
+		FROM urls`,
+		now(),
+	).Scan(&res.URLs, &res.Users, &res.Live, &res.Deleted, &res.Expired); err != nil {
+		return model.ServiceStats{}, fmt.Errorf("sqlite.Stats error: %w", err)
+	}
+
+	return res, nil
+}
+
+func (repo *urlRepository) ReassignUser(ctx context.Context, from, to string) (int, error) {
+	res, err := repo.db.ExecContext(ctx,
+		`UPDATE urls SET user_id = $2 WHERE user_id = $1`,
+		from, to,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite.ReassignUser error: update: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite.ReassignUser error: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (repo *urlRepository) queryURLs(ctx context.Context, query string, args ...any) ([]model.URLStore, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire a collection: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]model.URLStore, 0)
+	for rows.Next() {
+		u, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan a row: %w", err)
+		}
+		res = append(res, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("while reading: %w", err)
+	}
+
+	return res, nil
+}
+
+func insert(ctx context.Context, tx *sql.Tx, u model.URLStore) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO urls (user_id, short_url, original_url, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		u.UserID, u.Short, u.Original, utc(u.ExpiresAt),
+	)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanURL(row scanner) (model.URLStore, error) {
+	var u model.URLStore
+	err := row.Scan(&u.UUID, &u.UserID, &u.Short, &u.Original, &u.ExpiresAt, &u.DeletedFlag)
+	return u, err
+}
+
+func check(u model.URLStore) (model.URLStore, error) {
+	if u.DeletedFlag {
+		return model.URLStore{}, model.ErrDeleted
+	}
+	if u.Expired(time.Now()) {
+		return model.URLStore{}, model.ErrExpired
+	}
+
+	return u, nil
+}
+
+// placeholders appends vals to args and returns their placeholders for
+// an IN list, since SQLite has no arrays.
+func placeholders(args *[]any, vals []string) string {
+	ph := make([]string, len(vals))
+	for i, v := range vals {
+		*args = append(*args, v)
+		ph[i] = fmt.Sprintf("$%d", len(*args))
+	}
+	return strings.Join(ph, ", ")
+}
+
+// Times are compared as text, which only orders them correctly when all
+// of them are in UTC, so every time is converted before it is written.
+func now() time.Time { return time.Now().UTC() }
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func isUniqueViolation(err error) bool {
+	var sqlErr *sqlitedrv.Error
+	return errors.As(err, &sqlErr) && sqlErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// isShortViolation reports a clash on the short_url index as opposed to
+// the original_url one. SQLite names the columns, not the index.
+func isShortViolation(err error) bool {
+	return isUniqueViolation(err) && strings.Contains(err.Error(), "urls.short_url")
+}
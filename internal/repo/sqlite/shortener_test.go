@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+	"shortener/internal/shared/database/migrate"
+	"shortener/internal/shared/database/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepo returns a repository on a fresh database file that lives
+// as long as the test.
+func newTestRepo(t *testing.T) *urlRepository {
+	t.Helper()
+
+	ctx := context.Background()
+	db, err := sqlite.NewConnect(ctx, sqlite.Scheme+filepath.Join(t.TempDir(), "shortener.db"))
+	require.NoError(t, err)
+	repo, err := NewURLRepository(ctx, db)
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository { return newTestRepo(t) })
+}
+
+func TestClicks(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repotest.ClickStore { return newTestRepo(t) })
+}
+
+func TestUsers(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) repotest.UserStore { return newTestRepo(t) })
+}
+
+func TestAPIKeys(t *testing.T) {
+	repotest.RunAPIKeys(t, func(t *testing.T) service.APIKeyRepository { return newTestRepo(t) })
+}
+
+func TestAdmin(t *testing.T) {
+	repotest.RunAdmin(t, func(t *testing.T) repotest.AdminStore { return newTestRepo(t) })
+}
+
+func TestSequence(t *testing.T) {
+	repotest.RunSequence(t, func(t *testing.T) service.SequenceRepository { return newTestRepo(t) })
+}
+
+func TestMigrateDown(t *testing.T) {
+	repo := newTestRepo(t)
+	m, err := NewMigrator(repo.db)
+	require.NoError(t, err)
+
+	// Every migration reverts cleanly and can be applied again.
+	ctx := context.Background()
+	for {
+		err := m.Down(ctx)
+		if errors.Is(err, migrate.ErrNoMigrations) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+}
+
+func TestMigrateRenamesDuplicateCodes(t *testing.T) {
+	repo := newTestRepo(t)
+	m, err := NewMigrator(repo.db)
+	require.NoError(t, err)
+
+	// Back to the first version, when codes did not have to be unique.
+	ctx := context.Background()
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	for range status[1:] {
+		require.NoError(t, m.Down(ctx))
+	}
+	for _, original := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+		_, err := repo.db.ExecContext(ctx,
+			"INSERT INTO urls (user_id, short_url, original_url) VALUES ('user', 'dup', ?)", original)
+		require.NoError(t, err)
+	}
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	u, err := repo.Get(ctx, "dup")
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example", u.Original)
+	u, err = repo.Get(ctx, "dup-2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://b.example", u.Original)
+	u, err = repo.Get(ctx, "dup-3")
+	require.NoError(t, err)
+	assert.Equal(t, "https://c.example", u.Original)
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"shortener/internal/model"
+)
+
+func (repo *urlRepository) CreateUser(ctx context.Context, u model.User) error {
+	if _, err := repo.db.ExecContext(ctx,
+		`INSERT INTO users (id, email, password_hash, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		u.ID, u.Email, u.PasswordHash, u.Role, u.CreatedAt.UTC(),
+	); err != nil {
+		if isUniqueViolation(err) {
+			return model.ErrUserExists
+		}
+		return fmt.Errorf("sqlite.CreateUser error: insert: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *urlRepository) UserByEmail(ctx context.Context, email string) (model.User, error) {
+	return repo.user(ctx, "email", email)
+}
+
+func (repo *urlRepository) UserByID(ctx context.Context, id string) (model.User, error) {
+	return repo.user(ctx, "id", id)
+}
+
+// user looks a user up by a unique column.
+func (repo *urlRepository) user(ctx context.Context, column, value string) (model.User, error) {
+	var u model.User
+	if err := repo.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT id, email, password_hash, role, created_at FROM users WHERE %s = $1`, column),
+		value,
+	).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, fmt.Errorf("sqlite.user error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (repo *urlRepository) SetRole(ctx context.Context, userID, role string) error {
+	res, err := repo.db.ExecContext(ctx, `UPDATE users SET role = $2 WHERE id = $1`, userID, role)
+	if err != nil {
+		return fmt.Errorf("sqlite.SetRole error: %w", err)
+	}
+
+	return affected(res, model.ErrUserNotFound)
+}
+
+// affected returns notFound if res changed no rows.
+func affected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package traced records an OpenTelemetry span for every call to a
+// URLRepository, whatever the backend. Wrapped around the cache, the
+// spans also show whether the cache answered.
+package traced
+
+import (
+	"context"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/service"
+	"shortener/internal/shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("shortener/internal/repo")
+
+// outcomes are the errors reporting the state of a link rather than a
+// failure of the repository.
+var outcomes = []error{
+	model.ErrURLNotFound,
+	model.ErrDeleted,
+	model.ErrExpired,
+	model.ErrURLAlreadyExists,
+	model.ErrShortExists,
+}
+
+type urlRepository struct {
+	repo service.URLRepository
+}
+
+func NewURLRepository(repo service.URLRepository) *urlRepository {
+	return &urlRepository{repo: repo}
+}
+
+func (r *urlRepository) Ping(ctx context.Context) (err error) {
+	ctx, span := start(ctx, "Ping")
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.Ping(ctx)
+}
+
+func (r *urlRepository) Save(ctx context.Context, u model.URLStore) (_ string, err error) {
+	ctx, span := start(ctx, "Save", attribute.String("short", u.Short))
+	defer func() { tracing.End(span, err, outcomes...) }()
+
+	return r.repo.Save(ctx, u)
+}
+
+func (r *urlRepository) SaveAll(ctx context.Context, urls []model.URLStore) (err error) {
+	ctx, span := start(ctx, "SaveAll", attribute.Int("batch.size", len(urls)))
+	defer func() { tracing.End(span, err, outcomes...) }()
+
+	return r.repo.SaveAll(ctx, urls)
+}
+
+func (r *urlRepository) Get(ctx context.Context, short string) (_ model.URLStore, err error) {
+	ctx, span := start(ctx, "Get", attribute.String("short", short))
+	defer func() { tracing.End(span, err, outcomes...) }()
+
+	return r.repo.Get(ctx, short)
+}
+
+func (r *urlRepository) GetByID(ctx context.Context, uuid int) (_ model.URLStore, err error) {
+	ctx, span := start(ctx, "GetByID", attribute.Int("id", uuid))
+	defer func() { tracing.End(span, err, outcomes...) }()
+
+	return r.repo.GetByID(ctx, uuid)
+}
+
+func (r *urlRepository) GetAllByUser(ctx context.Context, userID string) (_ []model.URLStore, err error) {
+	ctx, span := start(ctx, "GetAllByUser")
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.GetAllByUser(ctx, userID)
+}
+
+func (r *urlRepository) DeleteBatch(ctx context.Context, userID string, urls []string) (err error) {
+	ctx, span := start(ctx, "DeleteBatch", attribute.Int("batch.size", len(urls)))
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.DeleteBatch(ctx, userID, urls)
+}
+
+func (r *urlRepository) DeleteExpired(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := start(ctx, "DeleteExpired")
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.DeleteExpired(ctx, before)
+}
+
+func (r *urlRepository) Stats(ctx context.Context) (_ model.ServiceStats, err error) {
+	ctx, span := start(ctx, "Stats")
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.Stats(ctx)
+}
+
+func (r *urlRepository) ReassignUser(ctx context.Context, from, to string) (_ int, err error) {
+	ctx, span := start(ctx, "ReassignUser")
+	defer func() { tracing.End(span, err) }()
+
+	return r.repo.ReassignUser(ctx, from, to)
+}
+
+func start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "URLRepository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
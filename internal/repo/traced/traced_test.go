@@ -0,0 +1,64 @@
+package traced
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"shortener/internal/model"
+	"shortener/internal/repo/memory"
+	"shortener/internal/repo/repotest"
+	"shortener/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// brokenRepo fails every ping.
+type brokenRepo struct {
+	service.URLRepository
+}
+
+func (brokenRepo) Ping(context.Context) error { return errors.New("connection refused") }
+
+func TestURLRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		mem, err := memory.NewURLRepository()
+		require.NoError(t, err)
+		return NewURLRepository(mem)
+	})
+}
+
+func TestSpans(t *testing.T) {
+	ctx := context.Background()
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	mem, err := memory.NewURLRepository()
+	require.NoError(t, err)
+	repo := NewURLRepository(mem)
+
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	_, err = repo.Get(ctx, "missing")
+	require.ErrorIs(t, err, model.ErrURLNotFound)
+	require.Error(t, NewURLRepository(brokenRepo{mem}).Ping(ctx))
+
+	spans := rec.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "URLRepository.Save", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("short", "abc"))
+
+	// A missing link is an outcome, not a failure.
+	assert.Equal(t, "URLRepository.Get", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+
+	assert.Equal(t, "URLRepository.Ping", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"shortener/internal/model"
+
+	"github.com/gofrs/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords are
+// refused rather than silently truncated.
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72
+)
+
+type UserRepository interface {
+	CreateUser(context.Context, model.User) error
+	UserByEmail(context.Context, string) (model.User, error)
+	UserByID(context.Context, string) (model.User, error)
+	SetRole(ctx context.Context, userID, role string) error
+}
+
+type accountService struct {
+	users UserRepository
+	urls  URLRepository
+	cost  int
+	now   func() time.Time
+	// dummyHash is checked when the email is unknown, so that a login
+	// takes as long as one with a wrong password.
+	dummyHash []byte
+}
+
+func NewAccountService(users UserRepository, urls URLRepository) *accountService {
+	return newAccountService(users, urls, bcrypt.DefaultCost)
+}
+
+func newAccountService(users UserRepository, urls URLRepository, cost int) *accountService {
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), cost)
+	return &accountService{users: users, urls: urls, cost: cost, now: time.Now, dummyHash: dummy}
+}
+
+// Register creates an account. An anonymous currentUserID becomes the
+// account's ID, so the links made so far stay with it.
+func (s *accountService) Register(ctx context.Context, currentUserID string, c model.Credentials) (model.User, error) {
+	email, err := normalizeEmail(c.Email)
+	if err != nil {
+		return model.User{}, err
+	}
+	if n := len(c.Password); n < minPasswordLen || n > maxPasswordLen {
+		return model.User{}, model.ErrInvalidPassword
+	}
+
+	id := currentUserID
+	anon, err := s.anonymous(ctx, currentUserID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("service.Register error: %w", err)
+	}
+	if !anon {
+		u, err := uuid.NewV4()
+		if err != nil {
+			return model.User{}, fmt.Errorf("service.Register error: new uuid: %w", err)
+		}
+		id = u.String()
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(c.Password), s.cost)
+	if err != nil {
+		return model.User{}, fmt.Errorf("service.Register error: hash password: %w", err)
+	}
+
+	u := model.User{ID: id, Email: email, PasswordHash: string(hash), CreatedAt: s.now().UTC()}
+	if err := s.users.CreateUser(ctx, u); err != nil {
+		if errors.Is(err, model.ErrUserExists) {
+			return model.User{}, err
+		}
+		return model.User{}, fmt.Errorf("service.Register error: %w", err)
+	}
+
+	return u, nil
+}
+
+// Login checks the credentials and moves the links of an anonymous
+// currentUserID into the account. It reports how many were moved.
+func (s *accountService) Login(ctx context.Context, currentUserID string, c model.Credentials) (model.User, int, error) {
+	email, err := normalizeEmail(c.Email)
+	if err != nil {
+		return model.User{}, 0, model.ErrInvalidCredentials
+	}
+
+	u, err := s.users.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(c.Password))
+			return model.User{}, 0, model.ErrInvalidCredentials
+		}
+		return model.User{}, 0, fmt.Errorf("service.Login error: %w", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(c.Password)); err != nil {
+		return model.User{}, 0, model.ErrInvalidCredentials
+	}
+
+	if currentUserID == "" || currentUserID == u.ID {
+		return u, 0, nil
+	}
+	// Links of another account are never merged, only anonymous ones.
+	anon, err := s.anonymous(ctx, currentUserID)
+	if err != nil {
+		return model.User{}, 0, fmt.Errorf("service.Login error: %w", err)
+	}
+	if !anon {
+		return u, 0, nil
+	}
+
+	n, err := s.urls.ReassignUser(ctx, currentUserID, u.ID)
+	if err != nil {
+		return model.User{}, 0, fmt.Errorf("service.Login error: claim links: %w", err)
+	}
+
+	return u, n, nil
+}
+
+// SetRole grants role to the account with email; an empty role revokes
+// it. Roles are only changed from the command line, never over the API.
+func (s *accountService) SetRole(ctx context.Context, email, role string) (model.User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	u, err := s.users.UserByEmail(ctx, email)
+	if err != nil {
+		return model.User{}, fmt.Errorf("service.SetRole error: %w", err)
+	}
+	if err := s.users.SetRole(ctx, u.ID, role); err != nil {
+		return model.User{}, fmt.Errorf("service.SetRole error: %w", err)
+	}
+
+	u.Role = role
+	return u, nil
+}
+
+// anonymous reports whether userID is set and belongs to no account.
+func (s *accountService) anonymous(ctx context.Context, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	_, err := s.users.UserByID(ctx, userID)
+	if errors.Is(err, model.ErrUserNotFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", model.ErrInvalidEmail
+	}
+	return email, nil
+}
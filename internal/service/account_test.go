@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountRegister(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := newAccountService(repo, repo, bcrypt.MinCost)
+
+	u, err := svc.Register(ctx, "anon", model.Credentials{Email: " Ann@Example.com ", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, "anon", u.ID, "an anonymous user keeps its ID")
+	assert.Equal(t, "ann@example.com", u.Email)
+	assert.NotEqual(t, "password", u.PasswordHash)
+
+	_, err = svc.Register(ctx, "other", model.Credentials{Email: "ann@example.com", Password: "password"})
+	assert.ErrorIs(t, err, model.ErrUserExists)
+
+	second, err := svc.Register(ctx, "anon", model.Credentials{Email: "bob@example.com", Password: "password"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "anon", second.ID, "an account's ID is not reused")
+
+	for _, c := range []model.Credentials{
+		{Email: "not an email", Password: "password"},
+		{Email: "Ann <ann2@example.com>", Password: "password"},
+		{Email: "ann2@example.com", Password: "short"},
+		{Email: "ann2@example.com", Password: strings.Repeat("x", 73)},
+	} {
+		_, err := svc.Register(ctx, "", c)
+		assert.Error(t, err, c)
+	}
+}
+
+func TestAccountLoginClaimsLinks(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := newAccountService(repo, repo, bcrypt.MinCost)
+
+	acc, err := svc.Register(ctx, "", model.Credentials{Email: "ann@example.com", Password: "password"})
+	require.NoError(t, err)
+	other, err := svc.Register(ctx, "", model.Credentials{Email: "bob@example.com", Password: "password"})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SaveAll(ctx, []model.URLStore{
+		{UserID: "anon", Short: "a1", Original: "https://example.com/1"},
+		{UserID: "anon", Short: "a2", Original: "https://example.com/2"},
+		{UserID: other.ID, Short: "b1", Original: "https://example.com/3"},
+	}))
+
+	_, _, err = svc.Login(ctx, "anon", model.Credentials{Email: "ann@example.com", Password: "wrong password"})
+	assert.ErrorIs(t, err, model.ErrInvalidCredentials)
+	_, _, err = svc.Login(ctx, "anon", model.Credentials{Email: "nobody@example.com", Password: "password"})
+	assert.ErrorIs(t, err, model.ErrInvalidCredentials)
+
+	u, claimed, err := svc.Login(ctx, "anon", model.Credentials{Email: "ANN@example.com", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, acc.ID, u.ID)
+	assert.Equal(t, 2, claimed)
+
+	urls, err := repo.GetAllByUser(ctx, acc.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	// Logging in from another account leaves its links alone.
+	_, claimed, err = svc.Login(ctx, other.ID, model.Credentials{Email: "ann@example.com", Password: "password"})
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+	urls, err = repo.GetAllByUser(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AdminRepository gives admins access to links regardless of owner and
+// state, and keeps the audit log of what they did.
+type AdminRepository interface {
+	// FindURL looks a link up by short code or, if short is empty, by
+	// original URL. Deleted and expired links are found too.
+	FindURL(ctx context.Context, short, original string) (model.URLStore, error)
+	// URLsByUser lists every link of a user, deleted and expired ones
+	// included.
+	URLsByUser(context.Context, string) ([]model.URLStore, error)
+	SetDeleted(ctx context.Context, short string, deleted bool) error
+	ReassignURL(ctx context.Context, short, to string) error
+	SaveAudit(context.Context, model.AuditEntry) error
+	// AuditLog returns the latest entries, newest first.
+	AuditLog(ctx context.Context, limit int) ([]model.AuditEntry, error)
+}
+
+type adminService struct {
+	repo  AdminRepository
+	users UserRepository
+	now   func() time.Time
+}
+
+func NewAdminService(repo AdminRepository, users UserRepository) *adminService {
+	return &adminService{repo: repo, users: users, now: time.Now}
+}
+
+func (s *adminService) Lookup(ctx context.Context, actor, short, original string) (model.AdminURL, error) {
+	if (short == "") == (original == "") {
+		return model.AdminURL{}, fmt.Errorf("%w: give either a short code or an original URL", model.ErrInvalidAdminReq)
+	}
+	if err := s.authorize(ctx, actor); err != nil {
+		return model.AdminURL{}, err
+	}
+
+	u, err := s.repo.FindURL(ctx, short, original)
+	if err != nil {
+		if errors.Is(err, model.ErrURLNotFound) {
+			return model.AdminURL{}, err
+		}
+		return model.AdminURL{}, fmt.Errorf("service.Lookup error: %w", err)
+	}
+	if err := s.audit(ctx, actor, model.AuditLookup, u.Short, ""); err != nil {
+		return model.AdminURL{}, err
+	}
+
+	return s.adminURL(u), nil
+}
+
+func (s *adminService) UserURLs(ctx context.Context, actor, userID string) ([]model.AdminURL, error) {
+	if err := s.authorize(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	urls, err := s.repo.URLsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service.UserURLs error: %w", err)
+	}
+	if err := s.audit(ctx, actor, model.AuditUserURLs, userID, ""); err != nil {
+		return nil, err
+	}
+
+	res := make([]model.AdminURL, 0, len(urls))
+	for _, u := range urls {
+		res = append(res, s.adminURL(u))
+	}
+	return res, nil
+}
+
+// Disable takes a link down; it then answers 410 like a deleted one.
+func (s *adminService) Disable(ctx context.Context, actor, short string) error {
+	return s.setDeleted(ctx, actor, short, true, model.AuditDisable)
+}
+
+func (s *adminService) Restore(ctx context.Context, actor, short string) error {
+	return s.setDeleted(ctx, actor, short, false, model.AuditRestore)
+}
+
+// Transfer gives a link to a registered account.
+func (s *adminService) Transfer(ctx context.Context, actor, short, to string) error {
+	if to == "" {
+		return fmt.Errorf("%w: user_id is empty", model.ErrInvalidAdminReq)
+	}
+	if err := s.authorize(ctx, actor); err != nil {
+		return err
+	}
+
+	if _, err := s.users.UserByID(ctx, to); err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			return fmt.Errorf("%w: user %s does not exist", model.ErrInvalidAdminReq, to)
+		}
+		return fmt.Errorf("service.Transfer error: %w", err)
+	}
+	u, err := s.find(ctx, short)
+	if err != nil {
+		return err
+	}
+	if err := s.audit(ctx, actor, model.AuditTransfer, short, fmt.Sprintf("from %s to %s", u.UserID, to)); err != nil {
+		return err
+	}
+	if err := s.repo.ReassignURL(ctx, short, to); err != nil {
+		return fmt.Errorf("service.Transfer error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *adminService) AuditLog(ctx context.Context, actor string, limit int) ([]model.AuditEntry, error) {
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	limit = min(limit, maxAuditLimit)
+	if err := s.authorize(ctx, actor); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.AuditLog(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("service.AuditLog error: %w", err)
+	}
+	if err := s.audit(ctx, actor, model.AuditReadAudit, "", ""); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Actions that change a link are audited before they are made, so none
+// goes unrecorded: if the audit entry cannot be saved, the action is not
+// made. The link is looked up first so that unknown codes are not
+// audited.
+func (s *adminService) setDeleted(ctx context.Context, actor, short string, deleted bool, action string) error {
+	if err := s.authorize(ctx, actor); err != nil {
+		return err
+	}
+
+	if _, err := s.find(ctx, short); err != nil {
+		return err
+	}
+	if err := s.audit(ctx, actor, action, short, ""); err != nil {
+		return err
+	}
+	if err := s.repo.SetDeleted(ctx, short, deleted); err != nil {
+		if errors.Is(err, model.ErrURLNotFound) {
+			return err
+		}
+		return fmt.Errorf("service.setDeleted error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *adminService) find(ctx context.Context, short string) (model.URLStore, error) {
+	u, err := s.repo.FindURL(ctx, short, "")
+	if err != nil {
+		if errors.Is(err, model.ErrURLNotFound) {
+			return model.URLStore{}, err
+		}
+		return model.URLStore{}, fmt.Errorf("service.find error: %w", err)
+	}
+	return u, nil
+}
+
+// authorize checks the stored role as well as the token's: a token keeps
+// its role until it expires, but a revoked admin must lose access at
+// once.
+func (s *adminService) authorize(ctx context.Context, actor string) error {
+	u, err := s.users.UserByID(ctx, actor)
+	if errors.Is(err, model.ErrUserNotFound) {
+		return model.ErrForbidden
+	}
+	if err != nil {
+		return fmt.Errorf("service.authorize error: %w", err)
+	}
+	if u.Role != model.RoleAdmin {
+		return model.ErrForbidden
+	}
+	return nil
+}
+
+func (s *adminService) audit(ctx context.Context, actor, action, target, detail string) error {
+	if err := s.repo.SaveAudit(ctx, model.AuditEntry{
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Detail: detail,
+		At:     s.now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("service.audit error: %w", err)
+	}
+	return nil
+}
+
+func (s *adminService) adminURL(u model.URLStore) model.AdminURL {
+	return model.AdminURL{URLStore: u, Deleted: u.DeletedFlag, Expired: u.Expired(s.now())}
+}
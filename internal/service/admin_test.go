@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminActions(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := NewAdminService(repo, repo)
+
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "admin", Email: "admin@example.com"}))
+	require.NoError(t, repo.SetRole(ctx, "admin", model.RoleAdmin))
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "u2", Email: "u2@example.com"}))
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+
+	u, err := svc.Lookup(ctx, "admin", "", "https://example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", u.Short)
+	assert.False(t, u.Deleted)
+
+	require.NoError(t, svc.Disable(ctx, "admin", "abc"))
+	u, err = svc.Lookup(ctx, "admin", "abc", "")
+	require.NoError(t, err)
+	assert.True(t, u.Deleted)
+
+	require.NoError(t, svc.Restore(ctx, "admin", "abc"))
+	require.NoError(t, svc.Transfer(ctx, "admin", "abc", "u2"))
+	urls, err := svc.UserURLs(ctx, "admin", "u2")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.False(t, urls[0].Deleted)
+
+	assert.ErrorIs(t, svc.Disable(ctx, "admin", "nope"), model.ErrURLNotFound)
+	_, err = svc.Lookup(ctx, "admin", "abc", "https://example.com")
+	assert.ErrorIs(t, err, model.ErrInvalidAdminReq)
+	assert.ErrorIs(t, svc.Transfer(ctx, "admin", "abc", ""), model.ErrInvalidAdminReq)
+	assert.ErrorIs(t, svc.Transfer(ctx, "admin", "abc", "ghost"), model.ErrInvalidAdminReq)
+	assert.ErrorIs(t, svc.Transfer(ctx, "admin", "nope", "u2"), model.ErrURLNotFound)
+
+	log, err := svc.AuditLog(ctx, "admin", 0)
+	require.NoError(t, err)
+	actions := make([]string, 0, len(log))
+	for _, e := range log {
+		assert.Equal(t, "admin", e.Actor)
+		actions = append(actions, e.Action)
+	}
+	// Newest first; failed actions are not recorded.
+	assert.Equal(t, []string{
+		model.AuditUserURLs, model.AuditTransfer, model.AuditRestore,
+		model.AuditLookup, model.AuditDisable, model.AuditLookup,
+	}, actions)
+	assert.Equal(t, "from u1 to u2", log[1].Detail)
+}
+
+func TestAdminForbidden(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := NewAdminService(repo, repo)
+
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "admin", Email: "admin@example.com"}))
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+
+	for _, actor := range []string{"admin", "stranger"} {
+		_, err = svc.Lookup(ctx, actor, "abc", "")
+		assert.ErrorIs(t, err, model.ErrForbidden)
+		assert.ErrorIs(t, svc.Disable(ctx, actor, "abc"), model.ErrForbidden)
+		_, err = svc.AuditLog(ctx, actor, 0)
+		assert.ErrorIs(t, err, model.ErrForbidden)
+	}
+
+	// Revoking the role takes effect at once.
+	require.NoError(t, repo.SetRole(ctx, "admin", model.RoleAdmin))
+	_, err = svc.Lookup(ctx, "admin", "abc", "")
+	require.NoError(t, err)
+	require.NoError(t, repo.SetRole(ctx, "admin", ""))
+	_, err = svc.Lookup(ctx, "admin", "abc", "")
+	assert.ErrorIs(t, err, model.ErrForbidden)
+
+	entries, err := repo.AuditLog(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// failingAuditRepo cannot save audit entries.
+type failingAuditRepo struct {
+	AdminRepository
+}
+
+func (failingAuditRepo) SaveAudit(context.Context, model.AuditEntry) error {
+	return errors.New("audit log is unavailable")
+}
+
+func TestAdminActionsNeedAudit(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := NewAdminService(failingAuditRepo{repo}, repo)
+
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "admin", Email: "admin@example.com", Role: model.RoleAdmin}))
+	require.NoError(t, repo.CreateUser(ctx, model.User{ID: "u2", Email: "u2@example.com"}))
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u1", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, svc.Disable(ctx, "admin", "abc"), "audit log is unavailable")
+	assert.ErrorContains(t, svc.Transfer(ctx, "admin", "abc", "u2"), "audit log is unavailable")
+
+	u, err := repo.FindURL(ctx, "abc", "")
+	require.NoError(t, err)
+	assert.False(t, u.DeletedFlag)
+	assert.Equal(t, "u1", u.UserID)
+}
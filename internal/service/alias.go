@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"shortener/internal/model"
+)
+
+// AliasPolicy describes which custom short codes users may request.
+// Reserved holds the first path segments of the service's own routes;
+// numeric aliases are always rejected because they address URLs by ID.
+type AliasPolicy struct {
+	Charset   string
+	MinLength int
+	MaxLength int
+	Reserved  []string
+}
+
+func (p AliasPolicy) Validate(alias string) error {
+	if len(alias) < p.MinLength || len(alias) > p.MaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d",
+			model.ErrInvalidAlias, p.MinLength, p.MaxLength)
+	}
+
+	for _, r := range alias {
+		if !strings.ContainsRune(p.Charset, r) {
+			return fmt.Errorf("%w: character %q is not allowed", model.ErrInvalidAlias, r)
+		}
+	}
+
+	if strings.Trim(alias, "0123456789") == "" {
+		return fmt.Errorf("%w: numeric aliases are reserved", model.ErrInvalidAlias)
+	}
+
+	for _, r := range p.Reserved {
+		if strings.EqualFold(alias, r) {
+			return fmt.Errorf("%w: %q is reserved", model.ErrInvalidAlias, alias)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAliases = AliasPolicy{
+	Charset:   "abcdefghijklmnopqrstuvwxyz0123456789-",
+	MinLength: 3,
+	MaxLength: 16,
+	Reserved:  []string{"api", "ping"},
+}
+
+func TestAliasPolicyValidate(t *testing.T) {
+	testCases := []struct {
+		alias   string
+		wantErr bool
+	}{
+		{alias: "spring-sale"},
+		{alias: "sale2025"},
+		{alias: "ab", wantErr: true},
+		{alias: "a-very-long-alias-indeed", wantErr: true},
+		{alias: "Spring", wantErr: true},
+		{alias: "with space", wantErr: true},
+		{alias: "12345", wantErr: true},
+		{alias: "ping", wantErr: true},
+		{alias: "API", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.alias, func(t *testing.T) {
+			err := testAliases.Validate(tc.alias)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, model.ErrInvalidAlias)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGenerateShortURLAlias(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", repo, codes, testAliases, 0)
+
+	short, err := svc.GenerateShortURL(ctx, "http", "u1", model.ShortenRequest{
+		URL:   "https://example.com/spring",
+		Alias: "spring-sale",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/spring-sale", short)
+
+	_, err = svc.GenerateShortURL(ctx, "http", "u2", model.ShortenRequest{
+		URL:   "https://example.com/other",
+		Alias: "spring-sale",
+	})
+	assert.ErrorIs(t, err, model.ErrAliasTaken)
+
+	_, err = svc.GenerateShortBatch(ctx, "http", "u2", []model.ShortenBatchRequest{
+		{CorrelationID: "1", Original: "https://example.com/b1"},
+		{CorrelationID: "2", Original: "https://example.com/b2", Alias: "spring-sale"},
+	})
+	assert.ErrorIs(t, err, model.ErrAliasTaken)
+
+	res, err := svc.GenerateShortBatch(ctx, "http", "u2", []model.ShortenBatchRequest{
+		{CorrelationID: "1", Original: "https://example.com/b1"},
+		{CorrelationID: "2", Original: "https://example.com/b2", Alias: "summer-sale"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/summer-sale", res[1].Short)
+}
+
+// racingAliasRepo takes the alias of a batch, for another user, just
+// before the batch is saved.
+type racingAliasRepo struct {
+	URLRepository
+	saves int
+}
+
+func (r *racingAliasRepo) SaveAll(ctx context.Context, urls []model.URLStore) error {
+	r.saves++
+	if r.saves == 1 {
+		if _, err := r.URLRepository.Save(ctx, model.URLStore{
+			UserID: "u1", Short: "flash-sale", Original: "https://example.com/first",
+		}); err != nil {
+			return err
+		}
+	}
+	return r.URLRepository.SaveAll(ctx, urls)
+}
+
+func TestGenerateShortBatchAliasTakenMeanwhile(t *testing.T) {
+	ctx := context.Background()
+
+	mem, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	repo := &racingAliasRepo{URLRepository: mem}
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", repo, codes, testAliases, 0)
+
+	_, err = svc.GenerateShortBatch(ctx, "http", "u2", []model.ShortenBatchRequest{
+		{CorrelationID: "1", Original: "https://example.com/b1"},
+		{CorrelationID: "2", Original: "https://example.com/b2", Alias: "flash-sale"},
+	})
+	assert.ErrorIs(t, err, model.ErrAliasTaken)
+	assert.Equal(t, 1, repo.saves, "a taken alias is not retried")
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	apiKeyPrefix = "shk_"
+	// apiKeyShown is how much of a key is kept in clear to identify it.
+	apiKeyShown   = len(apiKeyPrefix) + 6
+	maxKeyNameLen = 64
+	// touchEvery limits how often a key's last-used time is written.
+	touchEvery = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("API key is not valid")
+	ErrAPIKeyExpired = errors.New("API key has expired")
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(context.Context, model.APIKey) error
+	APIKeysByUser(context.Context, string) ([]model.APIKey, error)
+	APIKeyByHash(context.Context, string) (model.APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+type apiKeyService struct {
+	log  *logger.Logger
+	repo APIKeyRepository
+	now  func() time.Time
+}
+
+func NewAPIKeyService(log *logger.Logger, repo APIKeyRepository) *apiKeyService {
+	return &apiKeyService{log: log, repo: repo, now: time.Now}
+}
+
+// Create makes a key for userID. The key itself is only in the response.
+func (s *apiKeyService) Create(ctx context.Context, userID string, req model.APIKeyRequest) (model.APIKeyResponse, error) {
+	if err := s.validate(req); err != nil {
+		return model.APIKeyResponse{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return model.APIKeyResponse{}, fmt.Errorf("service.Create error: new uuid: %w", err)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.APIKeyResponse{}, fmt.Errorf("service.Create error: generate key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	k := model.APIKey{
+		ID:        id.String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyShown],
+		Hash:      hashAPIKey(key),
+		Scopes:    scopes,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, k); err != nil {
+		return model.APIKeyResponse{}, fmt.Errorf("service.Create error: %w", err)
+	}
+
+	return model.APIKeyResponse{APIKey: k, Key: key}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userID string) ([]model.APIKey, error) {
+	keys, err := s.repo.APIKeysByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service.List error: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID, id string) error {
+	if err := s.repo.DeleteAPIKey(ctx, userID, id); err != nil {
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			return err
+		}
+		return fmt.Errorf("service.Revoke error: %w", err)
+	}
+	return nil
+}
+
+// Resolve finds the key a client sent and records that it was used.
+func (s *apiKeyService) Resolve(ctx context.Context, key string) (model.APIKey, error) {
+	k, err := s.repo.APIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			return model.APIKey{}, ErrInvalidAPIKey
+		}
+		return model.APIKey{}, fmt.Errorf("service.Resolve error: %w", err)
+	}
+
+	now := s.now()
+	if k.Expired(now) {
+		return model.APIKey{}, ErrAPIKeyExpired
+	}
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= touchEvery {
+		// A failed write only makes the last-used time stale.
+		if err := s.repo.TouchAPIKey(ctx, k.ID, now.UTC()); err != nil {
+			s.log.Error("touch API key", logger.String("op", "service.Resolve"), logger.Error(err))
+		}
+	}
+
+	return k, nil
+}
+
+func (s *apiKeyService) validate(req model.APIKeyRequest) error {
+	if len(req.Name) > maxKeyNameLen {
+		return fmt.Errorf("%w: name is longer than %d bytes", model.ErrInvalidAPIKeyReq, maxKeyNameLen)
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("%w: no scopes", model.ErrInvalidAPIKeyReq)
+	}
+	for i, sc := range req.Scopes {
+		if !slices.Contains(model.Scopes, sc) {
+			return fmt.Errorf("%w: unknown scope %q", model.ErrInvalidAPIKeyReq, sc)
+		}
+		if slices.Contains(req.Scopes[:i], sc) {
+			return fmt.Errorf("%w: duplicate scope %q", model.ErrInvalidAPIKeyReq, sc)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(s.now()) {
+		return fmt.Errorf("%w: expires_at is in the past", model.ErrInvalidAPIKeyReq)
+	}
+	return nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
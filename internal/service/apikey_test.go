@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+	"shortener/internal/shared/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := NewAPIKeyService(logger.L(), repo)
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	created, err := svc.Create(ctx, "u1", model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeShorten, model.ScopeRead}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	assert.NotContains(t, created.Hash, created.Key)
+	assert.Equal(t, []string{model.ScopeRead, model.ScopeShorten}, created.Scopes)
+
+	k, err := svc.Resolve(ctx, created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", k.UserID)
+
+	keys, err := svc.List(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].LastUsedAt)
+	assert.True(t, now.Equal(*keys[0].LastUsedAt))
+
+	// Uses within touchEvery are not written.
+	now = now.Add(touchEvery / 2)
+	_, err = svc.Resolve(ctx, created.Key)
+	require.NoError(t, err)
+	keys, err = svc.List(ctx, "u1")
+	require.NoError(t, err)
+	assert.False(t, now.Equal(*keys[0].LastUsedAt))
+
+	_, err = svc.Resolve(ctx, created.Key+"x")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	assert.ErrorIs(t, svc.Revoke(ctx, "u2", created.ID), model.ErrAPIKeyNotFound)
+	require.NoError(t, svc.Revoke(ctx, "u1", created.ID))
+	_, err = svc.Resolve(ctx, created.Key)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	svc := NewAPIKeyService(logger.L(), repo)
+
+	exp := time.Now().Add(time.Hour)
+	created, err := svc.Create(ctx, "u1", model.APIKeyRequest{Scopes: []string{model.ScopeRead}, ExpiresAt: &exp})
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return exp }
+	_, err = svc.Resolve(ctx, created.Key)
+	assert.ErrorIs(t, err, ErrAPIKeyExpired)
+}
+
+func TestAPIKeyValidation(t *testing.T) {
+	svc := NewAPIKeyService(logger.L(), nil)
+	past := time.Now().Add(-time.Minute)
+
+	for name, req := range map[string]model.APIKeyRequest{
+		"no scopes":       {},
+		"unknown scope":   {Scopes: []string{"admin"}},
+		"duplicate scope": {Scopes: []string{model.ScopeRead, model.ScopeRead}},
+		"long name":       {Name: strings.Repeat("x", maxKeyNameLen+1), Scopes: []string{model.ScopeRead}},
+		"expired":         {Scopes: []string{model.ScopeRead}, ExpiresAt: &past},
+	} {
+		_, err := svc.Create(context.Background(), "u1", req)
+		assert.ErrorIs(t, err, model.ErrInvalidAPIKeyReq, name)
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	ctx := context.Background()
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	keys := NewAPIKeyService(logger.L(), repo)
+	auth, err := NewAuthService(logger.L(), []model.SigningKey{{Secret: []byte("secret")}}, keys, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+
+	readOnly, err := keys.Create(ctx, "u1", model.APIKeyRequest{Scopes: []string{model.ScopeRead}})
+	require.NoError(t, err)
+	token, _, err := auth.IssueToken("u1", "")
+	require.NoError(t, err)
+
+	var gotUser string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = auth.UserIDFromContext(r.Context())
+	})
+	read := auth.RequireUser(auth.RequireScope(model.ScopeRead)(ok))
+	del := auth.RequireUser(auth.RequireScope(model.ScopeDelete)(ok))
+	session := auth.RequireUser(auth.RequireSession(ok))
+	checkIn := auth.CheckInMiddleware(ok)
+
+	testCases := []struct {
+		name       string
+		h          http.Handler
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{name: "key with scope", h: read, header: apiKeyHeader, value: readOnly.Key, wantStatus: http.StatusOK},
+		{name: "key without scope", h: del, header: apiKeyHeader, value: readOnly.Key, wantStatus: http.StatusForbidden},
+		{name: "key on session route", h: session, header: apiKeyHeader, value: readOnly.Key, wantStatus: http.StatusForbidden},
+		{name: "token has every scope", h: del, header: authHeader, value: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "token on session route", h: session, header: authHeader, value: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "unknown key", h: read, header: apiKeyHeader, value: "shk_nope", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key does not check in", h: checkIn, header: apiKeyHeader, value: "shk_nope", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotUser = ""
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(tc.header, tc.value)
+			w := httptest.NewRecorder()
+
+			tc.h.ServeHTTP(w, r)
+
+			require.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				assert.Equal(t, "u1", gotUser)
+			} else {
+				assert.Empty(t, gotUser)
+			}
+		})
+	}
+}
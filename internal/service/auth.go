@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"shortener/internal/model"
@@ -12,29 +15,113 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-const cookieTokenName string = "token"
+const (
+	cookieTokenName string = "token"
+	// authHeader carries "Bearer <token>" for clients that cannot keep
+	// cookies.
+	authHeader   string = "Authorization"
+	bearerScheme string = "Bearer"
+	apiKeyHeader string = "X-API-Key"
+	// tokenHeader hands a new or refreshed token back to the client.
+	tokenHeader string = "X-Auth-Token"
+)
 
 type userIDKey struct{}
 
+// roleKey holds the role claimed by the request's token.
+type roleKey struct{}
+
+// scopesKey holds the scopes of the API key a request was made with.
+// Requests authenticated by a token have none and may do anything.
+type scopesKey struct{}
+
 var (
-	ErrNotValid error = errors.New("token is not valid")
-	ErrEmpty    error = errors.New("token is empty")
+	ErrNotValid   error = errors.New("token is not valid")
+	ErrUnknownKey error = errors.New("token is signed with an unknown key")
+	ErrNoKeys     error = errors.New("no signing keys")
+)
+
+// Reasons given with 401 responses so clients can tell whether to log
+// in again or just fetch a new token.
+const (
+	ReasonMissingToken = "missing_token"
+	ReasonExpiredToken = "token_expired"
+	ReasonUnknownKey   = "unknown_key"
+	ReasonInvalidToken = "invalid_token"
+
+	ReasonInvalidAPIKey = "invalid_api_key"
+	ReasonExpiredAPIKey = "api_key_expired"
+)
+
+// Reasons given with 403 responses to API key requests.
+const (
+	ReasonInsufficientScope = "insufficient_scope"
+	ReasonSessionRequired   = "session_required"
+	ReasonRoleRequired      = "role_required"
 )
 
+type APIKeyResolver interface {
+	Resolve(context.Context, string) (model.APIKey, error)
+}
+
+// RoleResolver reads users' current roles; see UserRepository.
+type RoleResolver interface {
+	UserByID(context.Context, string) (model.User, error)
+}
+
 type authService struct {
-	log         *logger.Logger
-	secret      []byte
+	log *logger.Logger
+	// keys verify tokens; the first one also signs new tokens.
+	keys []model.SigningKey
+	// apiKeys resolves X-API-Key headers; nil ignores them.
+	apiKeys APIKeyResolver
+	// roles gives refreshed tokens the user's current role; nil drops
+	// the role on refresh.
+	roles       RoleResolver
 	expireAfter time.Duration
+	// refreshBefore is how close to expiry a valid token gets replaced
+	// by a fresh one; zero disables sliding expiry.
+	refreshBefore time.Duration
+	// secureCookie marks the token cookie HTTPS-only.
+	secureCookie bool
 }
 
-func NewAuthService(log *logger.Logger, secret []byte, expireAfter time.Duration) *authService {
-	return &authService{
-		log:         log,
-		secret:      secret,
-		expireAfter: expireAfter,
+// NewAuthService signs tokens with keys[0] and accepts tokens signed
+// with any of keys, so a new key can be put first while the old ones
+// keep working until their tokens expire.
+func NewAuthService(
+	log *logger.Logger,
+	keys []model.SigningKey,
+	apiKeys APIKeyResolver,
+	roles RoleResolver,
+	expireAfter time.Duration,
+	refreshBefore time.Duration,
+	secureCookie bool,
+) (*authService, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("service.NewAuthService error: %w", ErrNoKeys)
+	}
+	for _, k := range keys {
+		if len(k.Secret) == 0 {
+			return nil, fmt.Errorf("service.NewAuthService error: key %q has an empty secret", k.ID)
+		}
 	}
+
+	return &authService{
+		log:           log,
+		keys:          keys,
+		apiKeys:       apiKeys,
+		roles:         roles,
+		expireAfter:   expireAfter,
+		refreshBefore: refreshBefore,
+		secureCookie:  secureCookie,
+	}, nil
 }
 
 func (s *authService) UserIDFromContext(ctx context.Context) (string, bool) {
@@ -42,63 +129,367 @@ func (s *authService) UserIDFromContext(ctx context.Context) (string, bool) {
 	return u, ok
 }
 
+// RoleFromContext returns the role of a request that passed RequireUser
+// with a token.
+func (s *authService) RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+// CheckInMiddleware guards endpoints that create data. A request
+// without a usable token gets a new identity instead of an error, so an
+// expired or tampered cookie never locks a browser out.
 func (s *authService) CheckInMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := s.getOrCreateUserID(w, r)
+	return s.apiKeyOr(next, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := s.checkIn(w, r)
 		if err != nil {
-			s.writeInternalError(w, err, "get or create user", "auth.CheckInMiddleware")
+			s.writeInternalError(w, err, "check in user", "auth.CheckInMiddleware")
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), userIDKey{}, userID)
 		next.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// RequireUser guards endpoints that read or change an existing user's
+// data. A missing or invalid token is answered with 401 and a reason.
+func (s *authService) RequireUser(next http.Handler) http.Handler {
+	return s.apiKeyOr(next, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := tokenFromRequest(r)
+		if !ok {
+			writeUnauthorized(w, ReasonMissingToken)
+			return
+		}
+		c, err := s.claims(token)
+		if err != nil {
+			writeUnauthorized(w, TokenReason(err))
+			return
+		}
+		s.refresh(r.Context(), w, c)
+
+		ctx := context.WithValue(r.Context(), userIDKey{}, c.UserID)
+		ctx = context.WithValue(ctx, roleKey{}, c.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// apiKeyOr authenticates requests carrying an X-API-Key header and
+// passes the others to fallback. A bad key is always a 401: clients
+// that use keys are not browsers to be handed a new identity.
+func (s *authService) apiKeyOr(next, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" || s.apiKeys == nil {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		k, err := s.apiKeys.Resolve(r.Context(), key)
+		switch {
+		case errors.Is(err, ErrInvalidAPIKey):
+			writeUnauthorized(w, ReasonInvalidAPIKey)
+			return
+		case errors.Is(err, ErrAPIKeyExpired):
+			writeUnauthorized(w, ReasonExpiredAPIKey)
+			return
+		case err != nil:
+			s.writeInternalError(w, err, "resolve API key", "auth.apiKeyOr")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey{}, k.UserID)
+		ctx = context.WithValue(ctx, scopesKey{}, k.Scopes)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope lets through token requests and API key requests whose
+// key has scope.
+func (s *authService) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scopes, ok := r.Context().Value(scopesKey{}).([]string); ok && !slices.Contains(scopes, scope) {
+				writeForbidden(w, ReasonInsufficientScope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole lets through requests whose token claims role. It must
+// come after RequireUser.
+func (s *authService) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.RoleFromContext(r.Context()) != role {
+				writeForbidden(w, ReasonRoleRequired)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession refuses API key requests, so that a key cannot mint
+// tokens or keys with more rights than its own.
+func (s *authService) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(scopesKey{}).([]string); ok {
+			writeForbidden(w, ReasonSessionRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
-func (s *authService) getOrCreateUserID(w http.ResponseWriter, r *http.Request) (string, error) {
-	cookie, err := r.Cookie(cookieTokenName)
+// CheckInInterceptor is the gRPC counterpart of CheckInMiddleware. The
+// token is read from the "token" metadata key, and a token minted for a
+// new user is sent back in the response header of the same name.
+// Unlike HTTP, a bad token fails the call: gRPC clients manage tokens
+// themselves and are better told than silently given a new identity.
+func (s *authService) CheckInInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var userID string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if token, ok := tokenFromMetadata(md); ok {
+		c, err := s.claims(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, TokenReason(err))
+		}
+		userID = c.UserID
+		if token, err := s.reissue(ctx, c); err != nil {
+			s.log.Error("refresh token", logger.String("op", "auth.CheckInInterceptor"), logger.Error(err))
+		} else if token != "" {
+			grpc.SetHeader(ctx, metadata.Pairs(cookieTokenName, token))
+		}
+	} else {
+		id, token, err := s.issue()
+		if err != nil {
+			s.log.Error("issue user", logger.String("op", "auth.CheckInInterceptor"), logger.Error(err))
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(cookieTokenName, token)); err != nil {
+			s.log.Error("set header", logger.String("op", "auth.CheckInInterceptor"), logger.Error(err))
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		userID = id
+	}
+
+	return handler(context.WithValue(ctx, userIDKey{}, userID), req)
+}
+
+func (s *authService) checkIn(w http.ResponseWriter, r *http.Request) (string, error) {
+	token, ok := tokenFromRequest(r)
+	if !ok {
+		return s.newUser(w)
+	}
+
+	c, err := s.claims(token)
 	if err != nil {
+		s.log.Info("token rejected, issuing a new user", logger.String("reason", TokenReason(err)))
 		return s.newUser(w)
 	}
-	return s.userID(cookie.Value)
+	s.refresh(r.Context(), w, c)
+
+	return c.UserID, nil
 }
 
-func (s *authService) newUser(w http.ResponseWriter) (string, error) {
-	userUUID, err := uuid.NewV4()
+// refresh sends a new token when c is about to expire. Failing to do so
+// is not fatal: the current token is still valid.
+func (s *authService) refresh(ctx context.Context, w http.ResponseWriter, c model.Claims) {
+	token, err := s.reissue(ctx, c)
 	if err != nil {
-		s.writeInternalError(w, err, "new uuid for user_id", "auth.newUser")
-		return "", err
+		s.log.Error("refresh token", logger.String("op", "auth.refresh"), logger.Error(err))
+		return
+	}
+	if token != "" {
+		s.setToken(w, token)
 	}
+}
 
-	userID := userUUID.String()
-	tokenString, err := s.createToken(userID)
+// reissue returns a new token for c's user when c is about to expire,
+// and "" otherwise. The role is read again rather than copied from c,
+// so that refreshing cannot keep a revoked role alive.
+func (s *authService) reissue(ctx context.Context, c model.Claims) (string, error) {
+	if !s.needsRefresh(c) {
+		return "", nil
+	}
+
+	var role string
+	if c.Role != "" && s.roles != nil {
+		u, err := s.roles.UserByID(ctx, c.UserID)
+		switch {
+		case err == nil:
+			role = u.Role
+		case !errors.Is(err, model.ErrUserNotFound):
+			return "", fmt.Errorf("read role: %w", err)
+		}
+	}
+
+	token, _, err := s.IssueToken(c.UserID, role)
+	return token, err
+}
+
+func (s *authService) needsRefresh(c model.Claims) bool {
+	return s.refreshBefore > 0 && c.ExpiresAt != nil && time.Until(c.ExpiresAt.Time) < s.refreshBefore
+}
+
+// TokenReason classifies why a token was rejected.
+func TokenReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ReasonExpiredToken
+	case errors.Is(err, ErrUnknownKey):
+		return ReasonUnknownKey
+	default:
+		return ReasonInvalidToken
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, reason string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, reason))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(model.ErrorResponse{Error: "unauthorized", Reason: reason})
+}
+
+func writeForbidden(w http.ResponseWriter, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(model.ErrorResponse{Error: "forbidden", Reason: reason})
+}
+
+// tokenFromRequest prefers a bearer token over the cookie.
+func tokenFromRequest(r *http.Request) (string, bool) {
+	if token, ok := bearerToken(r.Header.Get(authHeader)); ok {
+		return token, true
+	}
+	if cookie, err := r.Cookie(cookieTokenName); err == nil {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// tokenFromMetadata reads the "token" key, or "authorization" holding a
+// bearer token like the HTTP header.
+func tokenFromMetadata(md metadata.MD) (string, bool) {
+	if tokens := md.Get(cookieTokenName); len(tokens) > 0 {
+		return tokens[0], true
+	}
+	if vals := md.Get(authHeader); len(vals) > 0 {
+		return bearerToken(vals[0])
+	}
+	return "", false
+}
+
+func bearerToken(h string) (string, bool) {
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, bearerScheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// IssueToken signs a fresh token for userID with role, which is empty
+// for ordinary users, and reports when it expires.
+func (s *authService) IssueToken(userID, role string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(s.expireAfter)
+	token, err := s.createToken(userID, role, expiresAt)
 	if err != nil {
-		s.writeInternalError(w, err, "create token", "auth.newUser")
-		return "", err
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// SignIn hands the client a fresh token for userID, replacing the one it
+// had.
+func (s *authService) SignIn(w http.ResponseWriter, userID, role string) (string, time.Time, error) {
+	token, expiresAt, err := s.IssueToken(userID, role)
+	if err != nil {
+		return "", time.Time{}, err
 	}
+	s.setToken(w, token)
 
+	return token, expiresAt, nil
+}
+
+// SignOut removes the token cookie. Tokens are not revoked: a copy kept
+// by a bearer client stays valid until it expires.
+func (s *authService) SignOut(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     cookieTokenName,
-		Value:    tokenString,
+		Value:    "",
 		Path:     "/",
+		MaxAge:   -1,
 		HttpOnly: true,
-		// Secure:   true,
-		// SameSite: http.SameSiteStrictMode,
-		SameSite: http.SameSiteNoneMode,
+		Secure:   s.secureCookie,
+		SameSite: http.SameSiteLaxMode,
 	})
+}
+
+func (s *authService) newUser(w http.ResponseWriter) (string, error) {
+	userID, tokenString, err := s.issue()
+	if err != nil {
+		return "", err
+	}
+	s.setToken(w, tokenString)
 
 	return userID, nil
 }
 
-func (s *authService) createToken(userID string) (string, error) {
+// setToken hands a token to the client both as the cookie and in the
+// X-Auth-Token header.
+func (s *authService) setToken(w http.ResponseWriter, token string) {
+	w.Header().Set(tokenHeader, token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieTokenName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.secureCookie,
+		// Lax still sends the cookie when a user follows a link to the
+		// service, which Strict would not.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// issue creates a new user ID along with its signed token.
+func (s *authService) issue() (string, string, error) {
+	userUUID, err := uuid.NewV4()
+	if err != nil {
+		return "", "", fmt.Errorf("new uuid for user_id: %w", err)
+	}
+
+	userID := userUUID.String()
+	tokenString, _, err := s.IssueToken(userID, "")
+	if err != nil {
+		return "", "", err
+	}
+
+	return userID, tokenString, nil
+}
+
+func (s *authService) createToken(userID, role string, expiresAt time.Time) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, model.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expireAfter)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 		UserID: userID,
+		Role:   role,
 	})
 
-	tokenString, err := token.SignedString(s.secret)
+	if id := s.keys[0].ID; id != "" {
+		token.Header["kid"] = id
+	}
+
+	tokenString, err := token.SignedString(s.keys[0].Secret)
 	if err != nil {
 		return "", fmt.Errorf("unable to create string token: %w", err)
 	}
@@ -106,18 +497,18 @@ func (s *authService) createToken(userID string) (string, error) {
 	return tokenString, nil
 }
 
-func (s *authService) userID(tokenString string) (string, error) {
+func (s *authService) claims(tokenString string) (model.Claims, error) {
 	c := model.Claims{}
 	token, err := s.parseToken(tokenString, &c)
 	if err != nil {
-		return "", err
+		return model.Claims{}, err
 	}
 
-	if !token.Valid {
-		return "", ErrNotValid
+	if !token.Valid || c.UserID == "" {
+		return model.Claims{}, ErrNotValid
 	}
 
-	return c.UserID, nil
+	return c, nil
 }
 
 func (s *authService) parseToken(tokenString string, c *model.Claims) (*jwt.Token, error) {
@@ -126,7 +517,7 @@ func (s *authService) parseToken(tokenString string, c *model.Claims) (*jwt.Toke
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 			}
-			return s.secret, nil
+			return s.verificationKey(t)
 		})
 	if err != nil {
 		return nil, err
@@ -135,6 +526,26 @@ func (s *authService) parseToken(tokenString string, c *model.Claims) (*jwt.Toke
 	return token, nil
 }
 
+// verificationKey picks the key named by the kid header. Tokens without
+// one predate key rotation and are checked against every key.
+func (s *authService) verificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(s.keys))}
+		for _, k := range s.keys {
+			set.Keys = append(set.Keys, k.Secret)
+		}
+		return set, nil
+	}
+
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k.Secret, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
 func (s *authService) writeInternalError(
 	w http.ResponseWriter,
 	err error,
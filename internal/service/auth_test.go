@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+	"shortener/internal/shared/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuthServiceRequiresKeys(t *testing.T) {
+	_, err := NewAuthService(logger.L(), nil, nil, nil, time.Hour, 0, false)
+	assert.ErrorIs(t, err, ErrNoKeys)
+
+	_, err = NewAuthService(logger.L(), []model.SigningKey{{ID: "k1"}}, nil, nil, time.Hour, 0, false)
+	assert.Error(t, err)
+}
+
+func TestAuthKeyRotation(t *testing.T) {
+	k1 := model.SigningKey{ID: "k1", Secret: []byte("first secret")}
+	k2 := model.SigningKey{ID: "k2", Secret: []byte("second secret")}
+	legacy := model.SigningKey{Secret: []byte("legacy secret")}
+
+	old, err := NewAuthService(logger.L(), []model.SigningKey{k1}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+	oldToken, err := old.createToken("user-1", "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	pre, err := NewAuthService(logger.L(), []model.SigningKey{legacy}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+	legacyToken, err := pre.createToken("user-0", "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	rotated, err := NewAuthService(logger.L(), []model.SigningKey{k2, k1, legacy}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+
+	t.Run("signs with the first key", func(t *testing.T) {
+		token, err := rotated.createToken("user-2", "", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &model.Claims{})
+		require.NoError(t, err)
+		assert.Equal(t, "k2", parsed.Header["kid"])
+
+		c, err := rotated.claims(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user-2", c.UserID)
+
+		_, err = old.claims(token)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("accepts older keys", func(t *testing.T) {
+		c, err := rotated.claims(oldToken)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", c.UserID)
+	})
+
+	t.Run("accepts tokens without kid", func(t *testing.T) {
+		c, err := rotated.claims(legacyToken)
+		require.NoError(t, err)
+		assert.Equal(t, "user-0", c.UserID)
+	})
+
+	t.Run("rejects retired keys", func(t *testing.T) {
+		retired, err := NewAuthService(logger.L(), []model.SigningKey{k2}, nil, nil, time.Hour, 0, false)
+		require.NoError(t, err)
+
+		_, err = retired.claims(oldToken)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+		_, err = retired.claims(legacyToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestCheckInMiddlewareTokenSources(t *testing.T) {
+	svc, err := NewAuthService(logger.L(), []model.SigningKey{{ID: "k1", Secret: []byte("secret")}}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+
+	var got string
+	h := svc.CheckInMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = svc.UserIDFromContext(r.Context())
+	}))
+
+	// A new user gets the token both as a cookie and in the header.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	newUser := got
+	require.NotEmpty(t, newUser)
+
+	token := w.Header().Get("X-Auth-Token")
+	require.NotEmpty(t, token)
+	assert.Empty(t, w.Header().Get("Authorization"))
+	require.Len(t, w.Result().Cookies(), 1)
+	assert.Equal(t, token, w.Result().Cookies()[0].Value)
+
+	other, _, err := svc.IssueToken("other", "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+	}{
+		{name: "bearer", header: "Bearer " + token, want: newUser},
+		{name: "lower case scheme", header: "bearer " + token, want: newUser},
+		{name: "cookie", cookie: token, want: newUser},
+		{name: "bearer wins over cookie", header: "Bearer " + other, cookie: token, want: "other"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = ""
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "token", Value: tc.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.want, got)
+			assert.Empty(t, w.Header().Get("X-Auth-Token"))
+		})
+	}
+}
+
+func TestAuthMiddlewaresRejectedTokens(t *testing.T) {
+	svc, err := NewAuthService(logger.L(), []model.SigningKey{{ID: "k1", Secret: []byte("secret")}}, nil, nil, time.Hour, 10*time.Minute, false)
+	require.NoError(t, err)
+	other, err := NewAuthService(logger.L(), []model.SigningKey{{ID: "k9", Secret: []byte("other")}}, nil, nil, time.Hour, 0, false)
+	require.NoError(t, err)
+
+	valid, err := svc.createToken("user", "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	expiring, err := svc.createToken("user", "", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	expired, err := svc.createToken("user", "", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	foreign, err := other.createToken("user", "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		token      string
+		wantReason string
+		// wantNewToken is whether the response carries a token.
+		wantNewToken bool
+	}{
+		{name: "valid", token: valid},
+		{name: "expiring soon is refreshed", token: expiring, wantNewToken: true},
+		{name: "missing", wantReason: ReasonMissingToken, wantNewToken: true},
+		{name: "expired", token: expired, wantReason: ReasonExpiredToken, wantNewToken: true},
+		{name: "unknown key", token: foreign, wantReason: ReasonUnknownKey, wantNewToken: true},
+		{name: "garbage", token: "garbage", wantReason: ReasonInvalidToken, wantNewToken: true},
+	}
+
+	for _, tc := range testCases {
+		newRequest := func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			return r
+		}
+
+		t.Run("check in "+tc.name, func(t *testing.T) {
+			var got string
+			h := svc.CheckInMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = svc.UserIDFromContext(r.Context())
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newRequest())
+
+			require.Equal(t, http.StatusOK, w.Code)
+			if tc.wantReason == "" {
+				assert.Equal(t, "user", got)
+			} else {
+				assert.NotEqual(t, "user", got, "a bad token gets a new identity")
+			}
+			assert.Equal(t, tc.wantNewToken, w.Header().Get("X-Auth-Token") != "")
+		})
+
+		t.Run("require user "+tc.name, func(t *testing.T) {
+			var called bool
+			h := svc.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				got, _ := svc.UserIDFromContext(r.Context())
+				assert.Equal(t, "user", got)
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newRequest())
+
+			if tc.wantReason == "" {
+				assert.True(t, called)
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, tc.wantNewToken, w.Header().Get("X-Auth-Token") != "")
+				return
+			}
+			assert.False(t, called)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Empty(t, w.Header().Get("X-Auth-Token"))
+
+			var resp model.ErrorResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tc.wantReason, resp.Reason)
+		})
+	}
+}
+
+func TestRefreshReadsCurrentRole(t *testing.T) {
+	ctx := context.Background()
+	users, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	require.NoError(t, users.CreateUser(ctx, model.User{ID: "admin", Email: "admin@example.com", Role: model.RoleAdmin}))
+
+	svc, err := NewAuthService(logger.L(), []model.SigningKey{{ID: "k1", Secret: []byte("secret")}}, nil, users, time.Hour, 10*time.Minute, false)
+	require.NoError(t, err)
+
+	refresh := func(userID string) model.Claims {
+		t.Helper()
+		token, err := svc.createToken(userID, model.RoleAdmin, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+
+		h := svc.RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		c, err := svc.claims(w.Header().Get("X-Auth-Token"))
+		require.NoError(t, err)
+		return c
+	}
+
+	assert.Equal(t, model.RoleAdmin, refresh("admin").Role)
+
+	// Once revoked in storage, the role is not carried forward.
+	require.NoError(t, users.SetRole(ctx, "admin", ""))
+	assert.Empty(t, refresh("admin").Role)
+	assert.Empty(t, refresh("unknown").Role)
+}
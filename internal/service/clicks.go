@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"shortener/internal/model"
+	"shortener/internal/shared/logger"
+)
+
+type ClickRepository interface {
+	SaveClicks(context.Context, []model.Click) error
+}
+
+type clickService struct {
+	repo       ClickRepository
+	salt       []byte
+	ch         chan model.Click
+	done       chan struct{}
+	now        func() time.Time
+	flushEvery time.Duration
+}
+
+// NewClickService starts the pipeline that batches click events into
+// the repository. bufSize bounds the number of clicks waiting to be
+// written; when it is full, new clicks are dropped rather than slowing
+// redirects down.
+func NewClickService(ctx context.Context, repo ClickRepository, salt []byte, bufSize int) *clickService {
+	s := &clickService{
+		repo:       repo,
+		salt:       salt,
+		ch:         make(chan model.Click, bufSize),
+		done:       make(chan struct{}),
+		now:        time.Now,
+		flushEvery: time.Second,
+	}
+
+	s.saveBatch(ctx)
+	return s
+}
+
+// Record queues a click without blocking. clientIP is hashed here and
+// never leaves the service in clear.
+func (s *clickService) Record(c model.Click, clientIP string) {
+	if c.Time.IsZero() {
+		c.Time = s.now()
+	}
+	c.IPHash = s.hashIP(clientIP)
+
+	select {
+	case s.ch <- c:
+	default:
+		logger.L().Warn("click dropped: queue is full", logger.String("short", c.Short))
+	}
+}
+
+func (s *clickService) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *clickService) saveBatch(ctx context.Context) {
+	go func() {
+		defer close(s.done)
+
+		const maxBatchSize = 100
+		var (
+			ticker  = time.NewTicker(s.flushEvery)
+			pending = make([]model.Click, 0, maxBatchSize)
+		)
+		defer ticker.Stop()
+
+		flush := func(ctx context.Context) {
+			if len(pending) == 0 {
+				return
+			}
+			if err := s.repo.SaveClicks(ctx, pending); err != nil {
+				logger.L().Error("SaveClicks", logger.Error(err), logger.Int("dropped", len(pending)))
+			}
+			pending = make([]model.Click, 0, maxBatchSize)
+		}
+		for {
+			select {
+			case c := <-s.ch:
+				pending = append(pending, c)
+				if len(pending) >= maxBatchSize {
+					flush(ctx)
+				}
+			case <-ticker.C:
+				flush(ctx)
+			case <-ctx.Done():
+				dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+				defer cancel()
+
+				var n int
+				for drained := false; !drained; {
+					select {
+					case c := <-s.ch:
+						pending = append(pending, c)
+						n++
+						if len(pending) >= maxBatchSize {
+							flush(dctx)
+						}
+					default:
+						drained = true
+					}
+				}
+				flush(dctx)
+				logger.L().Info("click queue drained", logger.Int("queued", n))
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until queued clicks are written after the service context
+// is cancelled, or until ctx is done.
+func (s *clickService) Wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clickRepoStub struct {
+	mu     sync.Mutex
+	clicks []model.Click
+}
+
+func (r *clickRepoStub) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clicks = append(r.clicks, clicks...)
+	return nil
+}
+
+func TestClickServiceFlushesOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := &clickRepoStub{}
+	svc := NewClickService(ctx, repo, []byte("salt"), 10)
+
+	svc.Record(model.Click{Short: "abc", Referrer: "https://ref.example"}, "192.0.2.1")
+	svc.Record(model.Click{Short: "abc"}, "192.0.2.1")
+	svc.Record(model.Click{Short: "xyz"}, "")
+
+	cancel()
+	require.NoError(t, svc.Wait(context.Background()))
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	require.Len(t, repo.clicks, 3)
+	assert.NotEmpty(t, repo.clicks[0].IPHash)
+	assert.NotContains(t, repo.clicks[0].IPHash, "192.0.2.1")
+	assert.Equal(t, repo.clicks[0].IPHash, repo.clicks[1].IPHash)
+	assert.Empty(t, repo.clicks[2].IPHash)
+	assert.False(t, repo.clicks[0].Time.IsZero())
+}
+
+func TestClickServiceDropsWhenFull(t *testing.T) {
+	svc := &clickService{ch: make(chan model.Click, 1), now: time.Now}
+
+	svc.Record(model.Click{Short: "a"}, "")
+	svc.Record(model.Click{Short: "b"}, "")
+
+	assert.Len(t, svc.ch, 1)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	CodeStrategyHash    = "hash"
+	CodeStrategyRandom  = "random"
+	CodeStrategyCounter = "counter"
+
+	Base32HexAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+	Base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	maxCodeLength = 32
+)
+
+// CodeGenerator produces candidate short codes. attempt starts at zero
+// and grows each time the previous candidate collided with a stored code.
+type CodeGenerator interface {
+	Generate(ctx context.Context, original string, attempt int) (string, error)
+}
+
+// SequenceRepository hands out the numbers the counter strategy encodes.
+// Numbers start at 1 and are never handed out twice, across restarts
+// and replicas sharing the storage.
+type SequenceRepository interface {
+	NextSequence(ctx context.Context) (int64, error)
+}
+
+// NewCodeGenerator builds the generator for strategy. An empty alphabet
+// selects the strategy's default one. seq is only used, and then
+// required, by the counter strategy.
+func NewCodeGenerator(strategy, alphabet string, length int, seq SequenceRepository) (CodeGenerator, error) {
+	if alphabet == "" {
+		alphabet = Base62Alphabet
+		if strategy == CodeStrategyHash || strategy == "" {
+			alphabet = Base32HexAlphabet
+		}
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < 4 || length > maxCodeLength {
+		return nil, fmt.Errorf("code length must be between 4 and %d, got %d", maxCodeLength, length)
+	}
+
+	switch strategy {
+	case CodeStrategyHash, "":
+		return &hashCodeGenerator{alphabet: alphabet, length: length}, nil
+	case CodeStrategyRandom:
+		return &randomCodeGenerator{alphabet: alphabet, length: length}, nil
+	case CodeStrategyCounter:
+		if seq == nil {
+			return nil, errors.New("counter code strategy needs a sequence")
+		}
+		return &counterCodeGenerator{alphabet: alphabet, length: length, seq: seq}, nil
+	default:
+		return nil, fmt.Errorf("unknown code strategy %q", strategy)
+	}
+}
+
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 16 {
+		return errors.New("code alphabet must have at least 16 characters")
+	}
+
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if r > 0x7f {
+			return fmt.Errorf("code alphabet must be ASCII, got %q", r)
+		}
+		if _, ok := seen[r]; ok {
+			return fmt.Errorf("code alphabet has duplicate character %q", r)
+		}
+		seen[r] = struct{}{}
+	}
+
+	return nil
+}
+
+// hashCodeGenerator derives the code from SHA-256 of the original URL,
+// so shortening the same URL twice yields the same code. Every retry
+// salts the hash and adds one character.
+type hashCodeGenerator struct {
+	alphabet string
+	length   int
+}
+
+func (g *hashCodeGenerator) Generate(_ context.Context, original string, attempt int) (string, error) {
+	data := original
+	if attempt > 0 {
+		data += "#" + strconv.Itoa(attempt)
+	}
+	hash := sha256.Sum256([]byte(data))
+
+	return encode(new(big.Int).SetBytes(hash[:]), g.alphabet, min(g.length+attempt, maxCodeLength)), nil
+}
+
+// randomCodeGenerator draws every character uniformly from crypto/rand.
+type randomCodeGenerator struct {
+	alphabet string
+	length   int
+}
+
+func (g *randomCodeGenerator) Generate(_ context.Context, _ string, attempt int) (string, error) {
+	length := min(g.length+attempt/2, maxCodeLength)
+	base := big.NewInt(int64(len(g.alphabet)))
+
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", fmt.Errorf("random code: %w", err)
+		}
+		b[i] = g.alphabet[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// counterScramble multiplies sequence numbers so that consecutive codes
+// look unrelated. Being a prime larger than any alphabet, it is coprime
+// with every code space, which makes the multiplication a bijection.
+const counterScramble = 15485863
+
+// counterCodeGenerator encodes numbers drawn from a persisted sequence,
+// the way sqids and hashids encode database IDs: codes are unique and
+// decodable, and do not look sequential.
+//
+// Numbers fill the codes of the configured length first, then those one
+// character longer, and so on, so length is a minimum. Within a length,
+// the number is scrambled by a multiplication modulo the code space.
+type counterCodeGenerator struct {
+	alphabet string
+	length   int
+	seq      SequenceRepository
+}
+
+// Generate ignores attempt: a collision, with an alias or a code of
+// another strategy, is left behind by drawing the next number.
+func (g *counterCodeGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.seq.NextSequence(ctx)
+	if err != nil {
+		return "", fmt.Errorf("next sequence: %w", err)
+	}
+	if n < 1 {
+		return "", fmt.Errorf("sequence number %d out of range", n)
+	}
+
+	var (
+		base  = big.NewInt(int64(len(g.alphabet)))
+		v     = big.NewInt(n - 1)
+		space = new(big.Int).Exp(base, big.NewInt(int64(g.length)), nil)
+	)
+	length := g.length
+	for v.Cmp(space) >= 0 {
+		if length == maxCodeLength {
+			return "", fmt.Errorf("sequence number %d out of range", n)
+		}
+		v.Sub(v, space)
+		space.Mul(space, base)
+		length++
+	}
+
+	// v+1 rather than v keeps the first code off the all-zero digits.
+	v.Add(v, big.NewInt(1)).Mod(v, space)
+	v.Mul(v, big.NewInt(counterScramble)).Mod(v, space)
+
+	return encode(v, g.alphabet, length), nil
+}
+
+// decode returns the sequence number code was generated from.
+func (g *counterCodeGenerator) decode(code string) (int64, error) {
+	if len(code) < g.length || len(code) > maxCodeLength {
+		return 0, fmt.Errorf("code %q has the wrong length", code)
+	}
+
+	var (
+		base   = big.NewInt(int64(len(g.alphabet)))
+		v      = new(big.Int)
+		offset = new(big.Int)
+		space  = new(big.Int).Exp(base, big.NewInt(int64(g.length)), nil)
+	)
+	for range len(code) - g.length {
+		offset.Add(offset, space)
+		space.Mul(space, base)
+	}
+	for i := len(code) - 1; i >= 0; i-- {
+		d := strings.IndexByte(g.alphabet, code[i])
+		if d < 0 {
+			return 0, fmt.Errorf("code %q has a character outside the alphabet", code)
+		}
+		v.Mul(v, base).Add(v, big.NewInt(int64(d)))
+	}
+
+	inv := new(big.Int).ModInverse(big.NewInt(counterScramble), space)
+	v.Mul(v, inv).Mod(v, space)
+	v.Sub(v, big.NewInt(1)).Mod(v, space)
+
+	return v.Add(v, offset).Int64() + 1, nil
+}
+
+// encode writes the lowest length digits of n in base len(alphabet).
+func encode(n *big.Int, alphabet string, length int) string {
+	var (
+		base = big.NewInt(int64(len(alphabet)))
+		mod  = new(big.Int)
+		v    = new(big.Int).Set(n)
+		b    = make([]byte, length)
+	)
+	for i := range b {
+		v.DivMod(v, base, mod)
+		b[i] = alphabet[mod.Int64()]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCodeGenerator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy string
+		alphabet string
+		length   int
+		wantErr  bool
+	}{
+		{name: "hash default", strategy: CodeStrategyHash, length: 8},
+		{name: "random", strategy: CodeStrategyRandom, length: 6},
+		{name: "counter", strategy: CodeStrategyCounter, alphabet: "abcdefghijklmnopqrstuvwxyz", length: 7},
+		{name: "custom alphabet", strategy: CodeStrategyRandom, alphabet: "abcdefghijklmnopqrstuvwxyz", length: 7},
+		{name: "unknown strategy", strategy: "md5", length: 8, wantErr: true},
+		{name: "short alphabet", strategy: CodeStrategyHash, alphabet: "abc", length: 8, wantErr: true},
+		{name: "duplicate characters", strategy: CodeStrategyHash, alphabet: "aabcdefghijklmnopq", length: 8, wantErr: true},
+		{name: "too short", strategy: CodeStrategyRandom, length: 2, wantErr: true},
+		{name: "counter without sequence", strategy: CodeStrategyCounter, length: 8, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seq SequenceRepository
+			if tc.name != "counter without sequence" {
+				seq = &testSequence{}
+			}
+			g, err := NewCodeGenerator(tc.strategy, tc.alphabet, tc.length, seq)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			alphabet := tc.alphabet
+			if alphabet == "" {
+				alphabet = Base62Alphabet
+			}
+			code, err := g.Generate(context.Background(), "https://example.com", 0)
+			require.NoError(t, err)
+			assert.Len(t, code, tc.length)
+			for _, r := range code {
+				assert.True(t, strings.ContainsRune(alphabet, r), "unexpected character %q", r)
+			}
+		})
+	}
+}
+
+func TestHashCodeGenerator(t *testing.T) {
+	g, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+
+	first, _ := g.Generate(context.Background(), "https://example.com", 0)
+	again, _ := g.Generate(context.Background(), "https://example.com", 0)
+	retry, _ := g.Generate(context.Background(), "https://example.com", 1)
+
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first, retry[:8])
+	assert.Len(t, retry, 9)
+}
+
+// testSequence counts from next+1.
+type testSequence struct{ next int64 }
+
+func (s *testSequence) NextSequence(context.Context) (int64, error) {
+	s.next++
+	return s.next, nil
+}
+
+func TestCounterCodeGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unique and decodable", func(t *testing.T) {
+		g, err := NewCodeGenerator(CodeStrategyCounter, "", 4, &testSequence{})
+		require.NoError(t, err)
+		counter := g.(*counterCodeGenerator)
+
+		seen := make(map[string]struct{})
+		for n := int64(1); n <= 10000; n++ {
+			code, err := g.Generate(ctx, "https://example.com", 0)
+			require.NoError(t, err)
+			require.Len(t, code, 4)
+			if _, ok := seen[code]; ok {
+				t.Fatalf("code %q generated twice", code)
+			}
+			seen[code] = struct{}{}
+
+			got, err := counter.decode(code)
+			require.NoError(t, err)
+			require.Equal(t, n, got)
+		}
+	})
+
+	t.Run("length is a minimum", func(t *testing.T) {
+		const alphabet = "0123456789abcdef"
+		// 16^4 codes of length 4 exist, so this is the second past them.
+		seq := &testSequence{next: 1 << 16}
+		g, err := NewCodeGenerator(CodeStrategyCounter, alphabet, 4, seq)
+		require.NoError(t, err)
+
+		code, err := g.Generate(ctx, "", 0)
+		require.NoError(t, err)
+		assert.Len(t, code, 5)
+		for _, r := range code {
+			assert.True(t, strings.ContainsRune(alphabet, r), "unexpected character %q", r)
+		}
+		got, err := g.(*counterCodeGenerator).decode(code)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1<<16+1), got)
+	})
+
+	t.Run("shortening", func(t *testing.T) {
+		repo, err := mrepo.NewURLRepository()
+		require.NoError(t, err)
+		codes, err := NewCodeGenerator(CodeStrategyCounter, "", 6, repo)
+		require.NoError(t, err)
+		svc := NewURLService(ctx, "localhost:8080", repo, codes, AliasPolicy{}, 0)
+
+		first, err := svc.GenerateShortURL(ctx, "http", "u", model.ShortenRequest{URL: "https://example.com/1"})
+		require.NoError(t, err)
+		res, err := svc.GenerateShortBatch(ctx, "http", "u", []model.ShortenBatchRequest{
+			{CorrelationID: "1", Original: "https://example.com/2"},
+			{CorrelationID: "2", Original: "https://example.com/3"},
+		})
+		require.NoError(t, err)
+
+		for i, short := range []string{first, res[0].Short, res[1].Short} {
+			code := strings.TrimPrefix(short, "http://localhost:8080/")
+			n, err := codes.(*counterCodeGenerator).decode(code)
+			require.NoError(t, err)
+			assert.Equal(t, int64(i+1), n)
+		}
+	})
+
+	t.Run("foreign codes", func(t *testing.T) {
+		g, err := NewCodeGenerator(CodeStrategyCounter, "", 6, &testSequence{})
+		require.NoError(t, err)
+		counter := g.(*counterCodeGenerator)
+
+		for _, code := range []string{"abc", "abc-de"} {
+			_, err := counter.decode(code)
+			assert.Error(t, err, code)
+		}
+	})
+}
+
+// stuckCodeGenerator returns the same code for the first few attempts.
+type stuckCodeGenerator struct{ stuck int }
+
+func (g stuckCodeGenerator) Generate(_ context.Context, original string, attempt int) (string, error) {
+	if attempt < g.stuck {
+		return "taken", nil
+	}
+	return "fresh" + strings.Repeat("x", attempt), nil
+}
+
+func TestGenerateShortURLRetriesCollisions(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "u", Short: "taken", Original: "https://example.com/old"})
+	require.NoError(t, err)
+
+	svc := NewURLService(ctx, "localhost:8080", repo, stuckCodeGenerator{stuck: 2}, AliasPolicy{}, 0)
+	short, err := svc.GenerateShortURL(ctx, "http", "u", model.ShortenRequest{URL: "https://example.com/new"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/freshxx", short)
+
+	svc = NewURLService(ctx, "localhost:8080", repo, stuckCodeGenerator{stuck: maxCodeAttempts}, AliasPolicy{}, 0)
+	_, err = svc.GenerateShortURL(ctx, "http", "u", model.ShortenRequest{URL: "https://example.com/newer"})
+	assert.ErrorIs(t, err, model.ErrShortExists)
+}
@@ -2,15 +2,20 @@ package service
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/base32"
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"shortener/internal/model"
 	"shortener/internal/shared/logger"
+	"shortener/internal/shared/metrics"
+	"shortener/internal/shared/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type URLRepository interface {
@@ -21,47 +26,131 @@ type URLRepository interface {
 	GetByID(context.Context, int) (model.URLStore, error)
 	GetAllByUser(context.Context, string) ([]model.URLStore, error)
 	DeleteBatch(context.Context, string, []string) error
+	DeleteExpired(context.Context, time.Time) (int, error)
+	Stats(context.Context) (model.ServiceStats, error)
+	// ReassignUser moves every link of one user to another and reports
+	// how many were moved.
+	ReassignUser(ctx context.Context, from, to string) (int, error)
+}
+
+// drainTimeout bounds the writes made while draining a queue after the
+// service context is cancelled.
+const drainTimeout = 5 * time.Second
+
+var tracer = otel.Tracer("shortener/internal/service")
+
+// linkOutcomes are the errors reporting the state of a link rather than
+// a failure; spans do not record them as errors.
+var linkOutcomes = []error{
+	model.ErrURLNotFound,
+	model.ErrDeleted,
+	model.ErrExpired,
+	model.ErrURLAlreadyExists,
+	model.ErrAliasTaken,
 }
 
+// maxCodeAttempts bounds how many codes are tried before giving up on
+// a request whose codes keep colliding with stored ones.
+const maxCodeAttempts = 5
+
 type urlService struct {
 	baseAddr string
 	repo     URLRepository
+	codes    CodeGenerator
+	aliases  AliasPolicy
 	delCh    chan model.DeleteURLsRequest
+	queued   atomic.Int64
+	done     chan struct{}
+	reaped   chan struct{}
+	now      func() time.Time
 }
 
-func NewURLService(ctx context.Context, baseAddr string, repo URLRepository) *urlService {
+func NewURLService(
+	ctx context.Context,
+	baseAddr string,
+	repo URLRepository,
+	codes CodeGenerator,
+	aliases AliasPolicy,
+	reapEvery time.Duration,
+) *urlService {
 	s := &urlService{
 		baseAddr: strings.TrimRight(baseAddr, "/"),
 		repo:     repo,
+		codes:    codes,
+		aliases:  aliases,
 		delCh:    make(chan model.DeleteURLsRequest, 10),
+		done:     make(chan struct{}),
+		reaped:   make(chan struct{}),
+		now:      time.Now,
 	}
 
 	s.deleteBatch(ctx)
+	if reapEvery > 0 {
+		s.reapExpired(ctx, reapEvery)
+	} else {
+		close(s.reaped)
+	}
 	return s
 }
 
-func (s *urlService) GenerateShortURL(ctx context.Context, scheme, userID, original string) (string, error) {
-	if original == "" {
+func (s *urlService) GenerateShortURL(
+	ctx context.Context,
+	scheme string,
+	userID string,
+	req model.ShortenRequest,
+) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "urlService.GenerateShortURL")
+	defer func() { tracing.End(span, err, linkOutcomes...) }()
+
+	if req.URL == "" {
 		return "", errors.New("original URL is empty")
 	}
 	if scheme == "" {
 		return "", errors.New("scheme is empty")
 	}
-
-	shortURL, err := s.repo.Save(ctx,
-		model.URLStore{
-			UserID:   userID,
-			Short:    genShortURL(original),
-			Original: original},
-	)
-	if err != nil {
-		if errors.Is(err, model.ErrURLAlreadyExists) {
-			return s.shortWithScheme(scheme, shortURL), model.ErrURLAlreadyExists
+	if req.Alias != "" {
+		if err := s.aliases.Validate(req.Alias); err != nil {
+			return "", err
 		}
+	}
+	expiresAt, err := s.expiresAt(req.ExpiresAt, req.TTL)
+	if err != nil {
 		return "", err
 	}
 
-	return s.shortWithScheme(scheme, shortURL), nil
+	for attempt := 0; ; attempt++ {
+		short := req.Alias
+		if short == "" {
+			var err error
+			if short, err = s.codes.Generate(ctx, req.URL, attempt); err != nil {
+				return "", err
+			}
+		}
+
+		shortURL, err := s.repo.Save(ctx,
+			model.URLStore{
+				UserID:    userID,
+				Short:     short,
+				Original:  req.URL,
+				ExpiresAt: expiresAt},
+		)
+		if err != nil {
+			if errors.Is(err, model.ErrShortExists) && req.Alias != "" {
+				return "", model.ErrAliasTaken
+			}
+			if errors.Is(err, model.ErrShortExists) && attempt+1 < maxCodeAttempts {
+				continue
+			}
+			if errors.Is(err, model.ErrURLAlreadyExists) {
+				metrics.Conflicts.Inc()
+				return s.shortWithScheme(scheme, shortURL), model.ErrURLAlreadyExists
+			}
+			return "", err
+		}
+
+		metrics.Shortens.Inc()
+		return s.shortWithScheme(scheme, shortURL), nil
+	}
 }
 
 func (s *urlService) GenerateShortBatch(
@@ -69,23 +158,64 @@ func (s *urlService) GenerateShortBatch(
 	scheme string,
 	userID string,
 	req []model.ShortenBatchRequest,
-) ([]model.ShortenBatchResponse, error) {
+) (_ []model.ShortenBatchResponse, err error) {
+	ctx, span := tracer.Start(ctx, "urlService.GenerateShortBatch",
+		trace.WithAttributes(attribute.Int("batch.size", len(req))))
+	defer func() { tracing.End(span, err, linkOutcomes...) }()
+
 	if scheme == "" {
 		return []model.ShortenBatchResponse{}, errors.New("scheme is empty")
 	}
+	if err := s.checkBatchAliases(ctx, req); err != nil {
+		return []model.ShortenBatchResponse{}, err
+	}
 
-	urls := make([]model.URLStore, 0, len(req))
-	for _, u := range req {
-		urls = append(urls, model.URLStore{
-			UserID:   userID,
-			Short:    genShortURL(u.Original),
-			Original: u.Original,
-		})
+	expires := make([]*time.Time, len(req))
+	for i, u := range req {
+		at, err := s.expiresAt(u.ExpiresAt, u.TTL)
+		if err != nil {
+			return []model.ShortenBatchResponse{}, err
+		}
+		expires[i] = at
 	}
 
-	if err := s.repo.SaveAll(ctx, urls); err != nil {
-		return []model.ShortenBatchResponse{}, err
+	urls := make([]model.URLStore, len(req))
+	for attempt := 0; ; attempt++ {
+		for i, u := range req {
+			short := u.Alias
+			if short == "" {
+				var err error
+				if short, err = s.codes.Generate(ctx, u.Original, attempt); err != nil {
+					return []model.ShortenBatchResponse{}, err
+				}
+			}
+			urls[i] = model.URLStore{
+				UserID:    userID,
+				Short:     short,
+				Original:  u.Original,
+				ExpiresAt: expires[i],
+			}
+		}
+
+		err := s.repo.SaveAll(ctx, urls)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, model.ErrURLAlreadyExists) {
+			metrics.Conflicts.Inc()
+		}
+		if errors.Is(err, model.ErrShortExists) {
+			// Only generated codes change on retry: an alias taken since
+			// it was checked is reported as it is for a single link.
+			if err := s.checkBatchAliases(ctx, req); err != nil {
+				return []model.ShortenBatchResponse{}, err
+			}
+		}
+		if !errors.Is(err, model.ErrShortExists) || attempt+1 >= maxCodeAttempts {
+			return []model.ShortenBatchResponse{}, err
+		}
 	}
+	metrics.Shortens.Add(float64(len(urls)))
 
 	res := make([]model.ShortenBatchResponse, len(req))
 	for i := range req {
@@ -96,7 +226,64 @@ func (s *urlService) GenerateShortBatch(
 	return res, nil
 }
 
-func (s *urlService) OriginalURL(ctx context.Context, short string) (string, error) {
+// expiresAt resolves the optional absolute expiry or TTL of a request.
+func (s *urlService) expiresAt(at *time.Time, ttl int64) (*time.Time, error) {
+	switch {
+	case at != nil && ttl != 0:
+		return nil, fmt.Errorf("%w: expires_at and ttl are mutually exclusive", model.ErrInvalidExpiry)
+	case ttl < 0:
+		return nil, fmt.Errorf("%w: ttl must be positive", model.ErrInvalidExpiry)
+	case ttl > 0:
+		t := s.now().Add(time.Duration(ttl) * time.Second).UTC()
+		return &t, nil
+	case at != nil && !at.After(s.now()):
+		return nil, fmt.Errorf("%w: expires_at is in the past", model.ErrInvalidExpiry)
+	case at != nil:
+		t := at.UTC()
+		return &t, nil
+	}
+
+	return nil, nil
+}
+
+// checkBatchAliases validates the aliases of a batch up front. SaveAll
+// cannot tell which element collided, so taken aliases are looked up
+// before the batch is written.
+func (s *urlService) checkBatchAliases(ctx context.Context, req []model.ShortenBatchRequest) error {
+	seen := make(map[string]struct{})
+	for _, u := range req {
+		if u.Alias == "" {
+			continue
+		}
+		if err := s.aliases.Validate(u.Alias); err != nil {
+			return err
+		}
+		if _, ok := seen[u.Alias]; ok {
+			return model.ErrAliasTaken
+		}
+		seen[u.Alias] = struct{}{}
+
+		stored, err := s.repo.Get(ctx, u.Alias)
+		switch {
+		case err == nil && stored.Original == u.Original:
+			// SaveAll reports this one as an already shortened URL.
+		case err == nil, errors.Is(err, model.ErrDeleted):
+			return model.ErrAliasTaken
+		case errors.Is(err, model.ErrExpired):
+			// The expired record is purged when the batch is saved.
+		case !errors.Is(err, model.ErrURLNotFound):
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *urlService) OriginalURL(ctx context.Context, short string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "urlService.OriginalURL",
+		trace.WithAttributes(attribute.String("short", short)))
+	defer func() { tracing.End(span, err, linkOutcomes...) }()
+
 	if short == "" {
 		return "", errors.New("empty path")
 	}
@@ -109,11 +296,18 @@ func (s *urlService) OriginalURL(ctx context.Context, short string) (string, err
 	return u.Original, nil
 }
 
-func (s *urlService) URLByID(ctx context.Context, uuid int) (model.URLStore, error) {
+func (s *urlService) URLByID(ctx context.Context, uuid int) (_ model.URLStore, err error) {
+	ctx, span := tracer.Start(ctx, "urlService.URLByID",
+		trace.WithAttributes(attribute.Int("id", uuid)))
+	defer func() { tracing.End(span, err, linkOutcomes...) }()
+
 	return s.repo.GetByID(ctx, uuid)
 }
 
-func (s *urlService) UserStore(ctx context.Context, userID string) ([]model.URLStore, error) {
+func (s *urlService) UserStore(ctx context.Context, userID string) (_ []model.URLStore, err error) {
+	ctx, span := tracer.Start(ctx, "urlService.UserStore")
+	defer func() { tracing.End(span, err) }()
+
 	res, err := s.repo.GetAllByUser(ctx, userID)
 	if err != nil {
 		return []model.URLStore{}, err
@@ -127,12 +321,17 @@ func (s *urlService) UserStore(ctx context.Context, userID string) ([]model.URLS
 	return res, nil
 }
 
+// MakeDeleted queues req. Its links count towards the queue depth from
+// here, including while req waits in the channel.
 func (s *urlService) MakeDeleted(ctx context.Context, req model.DeleteURLsRequest) {
+	metrics.DeleteQueueDepth.Set(float64(s.queued.Add(int64(len(req.URLs)))))
 	s.delCh <- req
 }
 
 func (s *urlService) deleteBatch(ctx context.Context) {
 	go func() {
+		defer close(s.done)
+
 		const maxBatchSize = 20
 		var (
 			ticker  = time.NewTicker(1 * time.Second)
@@ -140,49 +339,104 @@ func (s *urlService) deleteBatch(ctx context.Context) {
 		)
 		defer ticker.Stop()
 
-		flush := func(pend model.DeleteURLsRequest) {
+		flush := func(ctx context.Context, pend model.DeleteURLsRequest) {
 			if len(pend.URLs) > 0 {
+				start := time.Now()
 				if err := s.repo.DeleteBatch(ctx, pend.UserID, pend.URLs); err != nil {
-					logger.L().Error("DeleteURLs", logger.ErrorS("empty URL"))
+					logger.L().Error("DeleteURLs", logger.Error(err), logger.String("user_id", pend.UserID))
 				}
+				metrics.DeleteFlushDuration.Observe(time.Since(start).Seconds())
 			}
+			metrics.DeleteQueueDepth.Set(float64(s.queued.Add(-int64(len(pend.URLs)))))
+		}
+		add := func(in model.DeleteURLsRequest) {
+			v := pending[in.UserID]
+			v.UserID = in.UserID
+			v.URLs = append(v.URLs, in.URLs...)
+			pending[in.UserID] = v
 		}
 		for {
 			select {
 			case in := <-s.delCh:
-				v := pending[in.UserID]
-				v.UserID = in.UserID
-				v.URLs = append(pending[in.UserID].URLs, in.URLs...)
-				pending[in.UserID] = v
+				add(in)
 				if len(pending[in.UserID].URLs) >= maxBatchSize {
-					flush(pending[in.UserID])
+					flush(ctx, pending[in.UserID])
 					delete(pending, in.UserID)
 				}
 			case <-ticker.C:
 				for k := range pending {
-					flush(pending[k])
+					flush(ctx, pending[k])
 					delete(pending, k)
 				}
 			case <-ctx.Done():
+				// ctx is gone by now, so what is still queued is written
+				// with a fresh one.
+				for drained := false; !drained; {
+					select {
+					case in := <-s.delCh:
+						add(in)
+					default:
+						drained = true
+					}
+				}
+
+				dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+				defer cancel()
 				for _, p := range pending {
-					flush(p)
+					flush(dctx, p)
 				}
+				logger.L().Info("delete queue drained", logger.Int("users", len(pending)))
 				return
 			}
 		}
 	}()
 }
 
-func (s *urlService) Ping(ctx context.Context) error { return s.repo.Ping(ctx) }
+// Wait blocks until the delete queue is drained and the expiry reaper
+// has stopped after the service context is cancelled, or until ctx is
+// done.
+func (s *urlService) Wait(ctx context.Context) error {
+	for _, ch := range []chan struct{}{s.done, s.reaped} {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
 
-func genShortURL(url string) string {
-	hash := sha256.Sum256([]byte(url))
-	base := base32.HexEncoding.EncodeToString(hash[:])
-	result := strings.ReplaceAll(base[:8], "=", "")
+// reapExpired purges expired links every interval until ctx is done.
+func (s *urlService) reapExpired(ctx context.Context, interval time.Duration) {
+	go func() {
+		defer close(s.reaped)
 
-	return result
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				n, err := s.repo.DeleteExpired(ctx, s.now())
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					logger.L().Error("reapExpired", logger.Error(err))
+					continue
+				}
+				if n > 0 {
+					logger.L().Info("expired URLs purged", logger.Int("count", n))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 }
 
+func (s *urlService) Ping(ctx context.Context) error { return s.repo.Ping(ctx) }
+
 func hasScheme(addr string) bool {
 	return strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://")
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+	"shortener/internal/shared/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateShortURLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	testCases := []struct {
+		name    string
+		req     model.ShortenRequest
+		want    *time.Time
+		wantErr bool
+	}{
+		{name: "no expiry", req: model.ShortenRequest{URL: "https://example.com/1"}},
+		{name: "ttl", req: model.ShortenRequest{URL: "https://example.com/2", TTL: 60}, want: ptr(now.Add(time.Minute))},
+		{name: "expires at", req: model.ShortenRequest{URL: "https://example.com/3", ExpiresAt: &future}, want: &future},
+		{name: "in the past", req: model.ShortenRequest{URL: "https://example.com/4", ExpiresAt: &past}, wantErr: true},
+		{name: "negative ttl", req: model.ShortenRequest{URL: "https://example.com/5", TTL: -1}, wantErr: true},
+		{name: "both", req: model.ShortenRequest{URL: "https://example.com/6", TTL: 60, ExpiresAt: &future}, wantErr: true},
+	}
+
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", repo, codes, AliasPolicy{}, 0)
+	svc.now = func() time.Time { return now }
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.GenerateShortURL(ctx, "http", "u", tc.req)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, model.ErrInvalidExpiry)
+				return
+			}
+			require.NoError(t, err)
+
+			urls, err := repo.GetAllByUser(ctx, "u")
+			require.NoError(t, err)
+			stored := urls[len(urls)-1]
+			if tc.want == nil {
+				assert.Nil(t, stored.ExpiresAt)
+				return
+			}
+			require.NotNil(t, stored.ExpiresAt)
+			assert.True(t, tc.want.Equal(*stored.ExpiresAt))
+		})
+	}
+}
+
+// ctxCheckingRepo fails writes made with a done context, as the
+// database backends do.
+type ctxCheckingRepo struct {
+	URLRepository
+}
+
+func (r ctxCheckingRepo) DeleteBatch(ctx context.Context, userID string, urls []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.URLRepository.DeleteBatch(ctx, userID, urls)
+}
+
+func TestDeleteQueueDrainsOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mem, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	repo := ctxCheckingRepo{mem}
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", repo, codes, AliasPolicy{}, 0)
+
+	for _, u := range []string{"https://example.com/1", "https://example.com/2"} {
+		_, err := svc.GenerateShortURL(ctx, "http", "u", model.ShortenRequest{URL: u})
+		require.NoError(t, err)
+	}
+	urls, err := repo.GetAllByUser(ctx, "u")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	svc.MakeDeleted(ctx, model.DeleteURLsRequest{UserID: "u", URLs: []string{urls[0].Short}})
+	svc.MakeDeleted(ctx, model.DeleteURLsRequest{UserID: "u", URLs: []string{urls[1].Short}})
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	require.NoError(t, svc.Wait(waitCtx))
+
+	left, err := repo.GetAllByUser(context.Background(), "u")
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+// blockingReaperRepo holds DeleteExpired until its context is done.
+type blockingReaperRepo struct {
+	URLRepository
+	started, finished chan struct{}
+}
+
+func (r blockingReaperRepo) DeleteExpired(ctx context.Context, _ time.Time) (int, error) {
+	close(r.started)
+	<-ctx.Done()
+	close(r.finished)
+	return 0, ctx.Err()
+}
+
+func TestWaitStopsReaper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mem, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	repo := blockingReaperRepo{mem, make(chan struct{}), make(chan struct{})}
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", repo, codes, AliasPolicy{}, time.Millisecond)
+
+	<-repo.started
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	require.NoError(t, svc.Wait(waitCtx))
+	select {
+	case <-repo.finished:
+	default:
+		t.Fatal("Wait returned while the reaper was running")
+	}
+}
+
+func TestDeleteQueueDepthCountsChannel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	codes, err := NewCodeGenerator(CodeStrategyHash, "", 8, nil)
+	require.NoError(t, err)
+	svc := NewURLService(ctx, "localhost:8080", mem, codes, AliasPolicy{}, 0)
+
+	start := testutil.ToFloat64(metrics.DeleteQueueDepth)
+	svc.MakeDeleted(ctx, model.DeleteURLsRequest{UserID: "u", URLs: []string{"a", "b"}})
+	assert.Equal(t, start+2, testutil.ToFloat64(metrics.DeleteQueueDepth))
+
+	cancel()
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), time.Second)
+	defer waitCancel()
+	require.NoError(t, svc.Wait(waitCtx))
+	assert.Equal(t, start, testutil.ToFloat64(metrics.DeleteQueueDepth))
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shortener/internal/model"
+)
+
+const (
+	defaultStatsWindow = 30 * 24 * time.Hour
+	maxStatsBuckets    = 24 * 366
+	statsTop           = 10
+)
+
+type ClickStatsRepository interface {
+	ClickStats(context.Context, string, model.StatsFilter) (model.LinkStats, error)
+}
+
+// LinkFinder finds a link whatever its state, so that owners keep access
+// to the statistics of deleted and expired links.
+type LinkFinder interface {
+	FindURL(ctx context.Context, short, original string) (model.URLStore, error)
+}
+
+type statsService struct {
+	urls   URLRepository
+	links  LinkFinder
+	clicks ClickStatsRepository
+	now    func() time.Time
+}
+
+func NewStatsService(urls URLRepository, links LinkFinder, clicks ClickStatsRepository) *statsService {
+	return &statsService{urls: urls, links: links, clicks: clicks, now: time.Now}
+}
+
+// LinkStats returns the click statistics of short to its owner, also
+// once the link is deleted or expired. Zero from and to default to the
+// last 30 days; an empty bucket to days.
+func (s *statsService) LinkStats(
+	ctx context.Context,
+	userID string,
+	short string,
+	f model.StatsFilter,
+) (model.LinkStats, error) {
+	f, err := s.normalize(f)
+	if err != nil {
+		return model.LinkStats{}, err
+	}
+
+	u, err := s.links.FindURL(ctx, short, "")
+	if err != nil {
+		return model.LinkStats{}, err
+	}
+	if u.UserID != userID {
+		return model.LinkStats{}, model.ErrForbidden
+	}
+
+	res, err := s.clicks.ClickStats(ctx, short, f)
+	if err != nil {
+		return model.LinkStats{}, err
+	}
+	res.Series = fillSeries(res.Series, f)
+
+	return res, nil
+}
+
+func (s *statsService) normalize(f model.StatsFilter) (model.StatsFilter, error) {
+	switch f.Bucket {
+	case "":
+		f.Bucket = model.BucketDay
+	case model.BucketHour, model.BucketDay:
+	default:
+		return f, fmt.Errorf("%w: bucket must be %q or %q", model.ErrInvalidStats, model.BucketHour, model.BucketDay)
+	}
+
+	if f.To.IsZero() {
+		f.To = s.now()
+	}
+	if f.From.IsZero() {
+		f.From = f.To.Add(-defaultStatsWindow)
+	}
+	f.From, f.To = f.From.UTC(), f.To.UTC()
+
+	if !f.From.Before(f.To) {
+		return f, fmt.Errorf("%w: from must be before to", model.ErrInvalidStats)
+	}
+	if f.To.Sub(f.From)/f.BucketSize() > maxStatsBuckets {
+		return f, fmt.Errorf("%w: range is too long for %s buckets", model.ErrInvalidStats, f.Bucket)
+	}
+
+	f.Top = statsTop
+	return f, nil
+}
+
+// fillSeries adds the empty buckets of the range, so charts get one
+// point per bucket.
+func fillSeries(sparse []model.StatsPoint, f model.StatsFilter) []model.StatsPoint {
+	byTime := make(map[int64]model.StatsPoint, len(sparse))
+	for _, p := range sparse {
+		byTime[p.Time.Unix()] = p
+	}
+
+	res := make([]model.StatsPoint, 0, int(f.To.Sub(f.From)/f.BucketSize())+1)
+	for t := f.BucketStart(f.From); t.Before(f.To); t = t.Add(f.BucketSize()) {
+		p, ok := byTime[t.Unix()]
+		if !ok {
+			p = model.StatsPoint{Time: t}
+		}
+		res = append(res, p)
+	}
+
+	return res
+}
+
+func (s *statsService) ServiceStats(ctx context.Context) (model.ServiceStats, error) {
+	return s.urls.Stats(ctx)
+}
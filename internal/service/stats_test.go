@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"shortener/internal/model"
+	mrepo "shortener/internal/repo/memory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkStats(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	repo, err := mrepo.NewURLRepository()
+	require.NoError(t, err)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "owner", Short: "abc", Original: "https://example.com"})
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+		{Time: day.Add(time.Hour), Short: "abc", IPHash: "ip1"},
+		{Time: day.Add(49 * time.Hour), Short: "abc", IPHash: "ip2"},
+	}))
+
+	svc := NewStatsService(repo, repo, repo)
+	svc.now = func() time.Time { return day.Add(72 * time.Hour) }
+
+	res, err := svc.LinkStats(ctx, "owner", "abc", model.StatsFilter{From: day})
+	require.NoError(t, err)
+	assert.Equal(t, model.BucketDay, res.Bucket)
+	assert.Equal(t, 2, res.TotalClicks)
+	require.Len(t, res.Series, 3)
+	assert.Equal(t, []int{1, 0, 1}, []int{res.Series[0].Clicks, res.Series[1].Clicks, res.Series[2].Clicks})
+
+	hourly, err := svc.LinkStats(ctx, "owner", "abc", model.StatsFilter{From: day, To: day.Add(6 * time.Hour), Bucket: "hour"})
+	require.NoError(t, err)
+	assert.Len(t, hourly.Series, 6)
+
+	_, err = svc.LinkStats(ctx, "intruder", "abc", model.StatsFilter{})
+	assert.ErrorIs(t, err, model.ErrForbidden)
+
+	_, err = svc.LinkStats(ctx, "owner", "missing", model.StatsFilter{})
+	assert.ErrorIs(t, err, model.ErrURLNotFound)
+
+	past := time.Now().Add(-time.Hour)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "owner", Short: "old", Original: "https://example.com/old", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = repo.Save(ctx, model.URLStore{UserID: "owner", Short: "gone", Original: "https://example.com/gone"})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteBatch(ctx, "owner", []string{"gone"}))
+	for _, short := range []string{"old", "gone"} {
+		_, err = svc.LinkStats(ctx, "owner", short, model.StatsFilter{From: day})
+		assert.NoError(t, err, short)
+		_, err = svc.LinkStats(ctx, "intruder", short, model.StatsFilter{From: day})
+		assert.ErrorIs(t, err, model.ErrForbidden, short)
+	}
+
+	_, err = svc.LinkStats(ctx, "owner", "abc", model.StatsFilter{Bucket: "week"})
+	assert.ErrorIs(t, err, model.ErrInvalidStats)
+
+	_, err = svc.LinkStats(ctx, "owner", "abc", model.StatsFilter{From: day, To: day.Add(-time.Hour)})
+	assert.ErrorIs(t, err, model.ErrInvalidStats)
+
+	_, err = svc.LinkStats(ctx, "owner", "abc", model.StatsFilter{From: day, To: day.AddDate(2, 0, 0), Bucket: "hour"})
+	assert.ErrorIs(t, err, model.ErrInvalidStats)
+}
@@ -0,0 +1,315 @@
+// Package migrate applies numbered SQL migrations to a database/sql
+// handle and records them in the schema_migrations table.
+//
+// Migrations are read from an fs.FS as pairs of files named
+// NNNN_description.up.sql and NNNN_description.down.sql.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"shortener/internal/shared/logger"
+)
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrNoMigrations = errors.New("no migrations to revert")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Locker serializes migrations between processes. Lock and Unlock are
+// called on the connection every migration runs on.
+type Locker interface {
+	Lock(context.Context, *sql.Conn) error
+	Unlock(context.Context, *sql.Conn) error
+}
+
+type Option func(*Migrator)
+
+// WithLocker sets the lock taken for the duration of Up and Down.
+func WithLocker(l Locker) Option {
+	return func(m *Migrator) { m.locker = l }
+}
+
+// OwnDB hands the database handle over to the migrator, which closes it
+// in Close. It suits handles opened only to migrate.
+func OwnDB() Option {
+	return func(m *Migrator) { m.ownDB = true }
+}
+
+type Migrator struct {
+	db         *sql.DB
+	ownDB      bool
+	migrations []Migration
+	locker     Locker
+}
+
+func New(db *sql.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	migrations, err := parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{db: db, migrations: migrations, locker: noopLocker{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// Close closes the database handle if the migrator owns it.
+func (m *Migrator) Close() error {
+	if !m.ownDB {
+		return nil
+	}
+	return m.db.Close()
+}
+
+// Up applies every pending migration in version order and returns the
+// number of migrations applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var n int
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, mg.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				mg.Version, mg.Name,
+			); err != nil {
+				return fmt.Errorf("migrate.Up error: version %d: %w", mg.Version, err)
+			}
+			logger.L().Info("migration applied",
+				logger.Int("version", int(mg.Version)),
+				logger.String("name", mg.Name),
+			)
+			n++
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if err := apply(ctx, conn, mg.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`,
+				mg.Version,
+			); err != nil {
+				return fmt.Errorf("migrate.Down error: version %d: %w", mg.Version, err)
+			}
+			logger.L().Info("migration reverted",
+				logger.Int("version", int(mg.Version)),
+				logger.String("name", mg.Name),
+			)
+			return nil
+		}
+		return ErrNoMigrations
+	})
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate.Status error: acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// A database that was never migrated has no table to read yet.
+	if err := createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		at, ok := applied[mg.Version]
+		res = append(res, Status{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return res, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate error: acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.locker.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("migrate error: take lock: %w", err)
+	}
+	defer func() {
+		if err := m.locker.Unlock(context.WithoutCancel(ctx), conn); err != nil {
+			logger.L().Error("migrate: release lock", logger.Error(err))
+		}
+	}()
+
+	if err := createTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func createTable(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			name VARCHAR NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	); err != nil {
+		return fmt.Errorf("migrate error: create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("migrate error: read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			v  int64
+			at time.Time
+		)
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("migrate error: scan schema_migrations: %w", err)
+		}
+		res[v] = at
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate error: read schema_migrations: %w", err)
+	}
+
+	return res, nil
+}
+
+// apply runs a migration script and its bookkeeping statement in one
+// transaction.
+func apply(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("start a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("execute script: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("update schema_migrations: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrate.parse error: read dir: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate.parse error: %s: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrate.parse error: %s: %w", e.Name(), err)
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migrate.parse error: version %d has two names: %s and %s", version, mg.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migrate.parse error: version %d needs both up and down scripts", mg.Version)
+		}
+		res = append(res, *mg)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
+
+type noopLocker struct{}
+
+func (noopLocker) Lock(context.Context, *sql.Conn) error   { return nil }
+func (noopLocker) Unlock(context.Context, *sql.Conn) error { return nil }
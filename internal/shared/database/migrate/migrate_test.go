@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"shortener/internal/shared/database/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sqlite.NewConnect(context.Background(), sqlite.Scheme+filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// scripts builds a migration directory from name → script pairs.
+func scripts(files map[string]string) fstest.MapFS {
+	fsys := make(fstest.MapFS, len(files))
+	for name, script := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(script)}
+	}
+	return fsys
+}
+
+// Versions are listed out of order on purpose: they run by number.
+var testMigrations = scripts(map[string]string{
+	"0010_c.up.sql":   `ALTER TABLE a ADD COLUMN c INTEGER`,
+	"0010_c.down.sql": `ALTER TABLE a DROP COLUMN c`,
+	"0001_a.up.sql":   `CREATE TABLE a (id INTEGER)`,
+	"0001_a.down.sql": `DROP TABLE a`,
+	"0002_b.up.sql":   `CREATE TABLE b (id INTEGER)`,
+	"0002_b.down.sql": `DROP TABLE b`,
+	"README.md":       `not a migration`,
+})
+
+func versions(st []Status, applied bool) []int64 {
+	var res []int64
+	for _, s := range st {
+		if s.Applied == applied {
+			res = append(res, s.Version)
+		}
+	}
+	return res
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	m, err := New(db, testMigrations)
+	require.NoError(t, err)
+
+	st, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 10}, versions(st, false))
+
+	n, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	st, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 10}, versions(st, true))
+	assert.Equal(t, "a", st[0].Name)
+	assert.False(t, st[0].AppliedAt.IsZero())
+
+	n, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Down reverts the latest migration each time.
+	require.NoError(t, m.Down(ctx))
+	st, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(st, true))
+	_, err = db.ExecContext(ctx, `SELECT c FROM a`)
+	assert.Error(t, err)
+
+	require.NoError(t, m.Down(ctx))
+	require.NoError(t, m.Down(ctx))
+	assert.ErrorIs(t, m.Down(ctx), ErrNoMigrations)
+
+	st, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions(st, true))
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	m, err := New(db, scripts(map[string]string{
+		"0001_a.up.sql":     `CREATE TABLE a (id INTEGER)`,
+		"0001_a.down.sql":   `DROP TABLE a`,
+		"0002_bad.up.sql":   `CREATE TABLE b (id INTEGER); INSERT INTO nowhere VALUES (1)`,
+		"0002_bad.down.sql": `DROP TABLE b`,
+		"0003_c.up.sql":     `CREATE TABLE c (id INTEGER)`,
+		"0003_c.down.sql":   `DROP TABLE c`,
+	}))
+	require.NoError(t, err)
+
+	n, err := m.Up(ctx)
+	require.ErrorContains(t, err, "version 2")
+	assert.Equal(t, 1, n)
+
+	st, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, versions(st, true))
+
+	// The failed migration was rolled back as a whole, and the next one
+	// never ran.
+	for _, table := range []string{"b", "c"} {
+		_, err = db.ExecContext(ctx, `SELECT id FROM `+table)
+		assert.Error(t, err, table)
+	}
+}
+
+// recordingLocker logs its calls and fails Lock with err.
+type recordingLocker struct {
+	calls []string
+	conns []*sql.Conn
+	err   error
+}
+
+func (l *recordingLocker) Lock(_ context.Context, conn *sql.Conn) error {
+	l.calls = append(l.calls, "lock")
+	l.conns = append(l.conns, conn)
+	return l.err
+}
+
+func (l *recordingLocker) Unlock(_ context.Context, conn *sql.Conn) error {
+	l.calls = append(l.calls, "unlock")
+	l.conns = append(l.conns, conn)
+	return nil
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("held around up and down", func(t *testing.T) {
+		l := &recordingLocker{}
+		m, err := New(newTestDB(t), testMigrations, WithLocker(l))
+		require.NoError(t, err)
+
+		_, err = m.Up(ctx)
+		require.NoError(t, err)
+		require.NoError(t, m.Down(ctx))
+		_, err = m.Status(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"lock", "unlock", "lock", "unlock"}, l.calls)
+		assert.Same(t, l.conns[0], l.conns[1])
+		assert.Same(t, l.conns[2], l.conns[3])
+	})
+
+	t.Run("released after a failure", func(t *testing.T) {
+		l := &recordingLocker{}
+		m, err := New(newTestDB(t), scripts(map[string]string{
+			"0001_bad.up.sql":   `INSERT INTO nowhere VALUES (1)`,
+			"0001_bad.down.sql": `SELECT 1`,
+		}), WithLocker(l))
+		require.NoError(t, err)
+
+		_, err = m.Up(ctx)
+		require.Error(t, err)
+		assert.Equal(t, []string{"lock", "unlock"}, l.calls)
+	})
+
+	t.Run("nothing runs without the lock", func(t *testing.T) {
+		l := &recordingLocker{err: errors.New("lock is busy")}
+		m, err := New(newTestDB(t), testMigrations, WithLocker(l))
+		require.NoError(t, err)
+
+		n, err := m.Up(ctx)
+		require.ErrorContains(t, err, "lock is busy")
+		assert.Zero(t, n)
+		assert.Equal(t, []string{"lock"}, l.calls)
+
+		st, err := m.Status(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, versions(st, true))
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "missing down", files: map[string]string{"0001_a.up.sql": `SELECT 1`}},
+		{name: "two names", files: map[string]string{
+			"0001_a.up.sql":   `SELECT 1`,
+			"0001_b.down.sql": `SELECT 1`,
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(newTestDB(t), scripts(tc.files))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDB(t)
+	m, err := New(db, testMigrations)
+	require.NoError(t, err)
+	require.NoError(t, m.Close())
+	assert.NoError(t, db.PingContext(ctx), "a borrowed handle stays open")
+
+	m, err = New(db, testMigrations, OwnDB())
+	require.NoError(t, err)
+	require.NoError(t, m.Close())
+	assert.Error(t, db.PingContext(ctx))
+}
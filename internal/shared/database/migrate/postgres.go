@@ -0,0 +1,20 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PostgresLocker takes a session-level advisory lock, so replicas
+// starting at the same time apply migrations one after another.
+type PostgresLocker int64
+
+func (l PostgresLocker) Lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, int64(l))
+	return err
+}
+
+func (l PostgresLocker) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, int64(l))
+	return err
+}
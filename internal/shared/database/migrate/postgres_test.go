@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresLocker(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	conn := func() *sql.Conn {
+		c, err := db.Conn(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	holder, other := conn(), conn()
+	tryLock := func() bool {
+		var ok bool
+		require.NoError(t, other.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, int64(testLockID)).Scan(&ok))
+		if ok {
+			_, err := other.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, int64(testLockID))
+			require.NoError(t, err)
+		}
+		return ok
+	}
+
+	require.NoError(t, testLockID.Lock(ctx, holder))
+	assert.False(t, tryLock(), "another session must wait for the lock")
+
+	require.NoError(t, testLockID.Unlock(ctx, holder))
+	assert.True(t, tryLock())
+}
+
+// testLockID is apart from the key the application migrates under.
+const testLockID PostgresLocker = 0x7465737400
@@ -4,13 +4,21 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 
 
 func NewConnect(ctx context.Context, connString string) (*pgxpool.Pool, error) {
-	db, err := pgxpool.New(ctx, connString)
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.NewConnect - unable to parse connection string: %w", err)
+	}
+	// Queries are traced under the span of the request making them.
+	cfg.ConnConfig.Tracer = otelpgx.NewTracer()
+
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("postgres.NewConnect - unable to create new pg pool: %w", err)
 	}
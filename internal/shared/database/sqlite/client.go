@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// Scheme prefixes the DSNs handled here, e.g. sqlite://tmp/shortener.db
+// or sqlite:///var/lib/shortener.db.
+const Scheme = "sqlite://"
+
+// pragmas apply to every connection. Times are written in a format SQLite
+// date functions understand and that sorts like the times themselves as
+// long as they are all UTC.
+const pragmas = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_time_format=sqlite"
+
+func IsDSN(dsn string) bool { return strings.HasPrefix(dsn, Scheme) }
+
+func NewConnect(ctx context.Context, dsn string) (*sql.DB, error) {
+	path, ok := strings.CutPrefix(dsn, Scheme)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("sqlite.NewConnect - invalid DSN, want %spath", Scheme)
+	}
+
+	// Like the file storage, create the directory the database lives in.
+	if name, _, _ := strings.Cut(path, "?"); name != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return nil, fmt.Errorf("sqlite.NewConnect - unable to create directory: %w", err)
+		}
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", "file:"+path+sep+pragmas)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite.NewConnect - unable to open database: %w", err)
+	}
+
+	// SQLite allows one writer at a time; a single connection avoids
+	// busy errors when a read transaction turns into a write, and keeps
+	// :memory: databases from being opened once per connection.
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite.NewConnect - failed to open %s: %w", path, err)
+	}
+
+	return db, nil
+}
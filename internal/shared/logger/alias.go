@@ -18,8 +18,10 @@ const (
 var (
 	String   = zap.String
 	Int      = zap.Int
+	Bool     = zap.Bool
 	Duration = zap.Duration
 	Error    = zap.Error
+	Any      = zap.Any
 )
 
 type (
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// InterceptorGRPC logs unary calls the way MiddlewareHTTP logs requests.
+func InterceptorGRPC(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+
+	resp, err := handler(ctx, req)
+
+	L().Info("",
+		String("method", info.FullMethod),
+		String("code", status.Code(err).String()),
+		Duration("time", time.Since(start)),
+	)
+
+	return resp, err
+}
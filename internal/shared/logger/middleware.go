@@ -24,26 +24,47 @@ func (w *loggingResponseWriter) WriteHeader(statusCode int) {
 	w.respData.status = statusCode
 }
 
+// RequestObserver receives what MiddlewareHTTP gathers about a request
+// once it has been served.
+type RequestObserver func(r *http.Request, status, size int, elapsed time.Duration)
+
 func MiddlewareHTTP(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		lw := loggingResponseWriter{
-			ResponseWriter: w,
-			respData: &struct {
-				status int
-				size   int
-			}{0, 0},
-		}
-
-		next.ServeHTTP(&lw, r)
-
-		L().Info("",
-			String("method", r.Method),
-			String("uri", r.RequestURI),
-			Int("status", lw.respData.status),
-			Duration("time", time.Since(start)),
-			Int("size", lw.respData.size),
-		)
-	})
+	return ObserveHTTP()(next)
+}
+
+// ObserveHTTP returns MiddlewareHTTP passing every request to observers
+// after logging it.
+func ObserveHTTP(observers ...RequestObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			lw := loggingResponseWriter{
+				ResponseWriter: w,
+				respData: &struct {
+					status int
+					size   int
+				}{0, 0},
+			}
+
+			next.ServeHTTP(&lw, r)
+
+			// net/http sends 200 when the handler never sets a status.
+			if lw.respData.status == 0 {
+				lw.respData.status = http.StatusOK
+			}
+			elapsed := time.Since(start)
+
+			L().Info("",
+				String("method", r.Method),
+				String("uri", r.RequestURI),
+				Int("status", lw.respData.status),
+				Duration("time", elapsed),
+				Int("size", lw.respData.size),
+			)
+			for _, observe := range observers {
+				observe(r, lw.respData.status, lw.respData.size, elapsed)
+			}
+		})
+	}
 }
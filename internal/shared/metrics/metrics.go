@@ -0,0 +1,158 @@
+// Package metrics keeps the Prometheus metrics of the service in a
+// registry of its own and serves them at /metrics.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortener"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	Redirects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Short links resolved, by HTTP redirect or gRPC Expand.",
+	})
+
+	Shortens = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shortens_total",
+		Help:      "Short links created.",
+	})
+
+	Conflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "shorten_conflicts_total",
+		Help:      "Shorten requests for a URL that was already shortened.",
+	})
+
+	DeleteQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_queue_depth",
+		Help:      "Links waiting to be marked deleted.",
+	})
+
+	DeleteFlushDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delete_flush_duration_seconds",
+		Help:      "Time taken to write a batch of deletions.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		Redirects, Shortens, Conflicts,
+		DeleteQueueDepth, DeleteFlushDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTP records a served request. It is a logger.RequestObserver.
+// Requests are labelled with their chi route pattern, not their path,
+// to keep the number of series bounded.
+func ObserveHTTP(r *http.Request, status, _ int, elapsed time.Duration) {
+	route := "unmatched"
+	if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+		route = rc.RoutePattern()
+	}
+
+	labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+	httpRequests.With(labels).Inc()
+	httpDuration.With(labels).Observe(elapsed.Seconds())
+}
+
+// RegisterPool exports the statistics of a pgx connection pool. A pool
+// registered before is replaced.
+func RegisterPool(pool *pgxpool.Pool) error {
+	if err := register(&poolCollector{pool: pool}); err != nil {
+		return fmt.Errorf("metrics.RegisterPool error: %w", err)
+	}
+	return nil
+}
+
+// RegisterCache exports the hit and miss counts of a cache, along with
+// its hit ratio. A cache registered before is replaced.
+func RegisterCache(counts func() (hits, misses uint64)) error {
+	if err := register(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Link reads served from the cache.",
+		}, func() float64 {
+			hits, _ := counts()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_misses_total",
+			Help:      "Link reads that went to the storage.",
+		}, func() float64 {
+			_, misses := counts()
+			return float64(misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_hit_ratio",
+			Help:      "Share of link reads served from the cache since startup.",
+		}, func() float64 {
+			hits, misses := counts()
+			if hits+misses == 0 {
+				return 0
+			}
+			return float64(hits) / float64(hits+misses)
+		}),
+	); err != nil {
+		return fmt.Errorf("metrics.RegisterCache error: %w", err)
+	}
+	return nil
+}
+
+// register adds cs to the registry, each in place of a collector
+// already exporting the same metrics, as when storage is opened again.
+func register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		err := registry.Register(c)
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			registry.Unregister(are.ExistingCollector)
+			err = registry.Register(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shortener/internal/shared/logger"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveHTTP(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(logger.ObserveHTTP(ObserveHTTP))
+	r.Get("/{short}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, path := range []string{"/abc", "/def", "/ping", "/a/b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Paths are grouped by route, and a handler that sets no status
+	// counts as 200.
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/{short}", "307")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/ping", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")))
+}
+
+func TestRegisterCacheTwice(t *testing.T) {
+	require.NoError(t, RegisterCache(func() (uint64, uint64) { return 1, 1 }))
+	require.NoError(t, RegisterCache(func() (uint64, uint64) { return 3, 1 }))
+
+	// The cache registered last is the one exported.
+	n, err := testutil.GatherAndCount(registry, "shortener_cache_hits_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP shortener_cache_hit_ratio Share of link reads served from the cache since startup.
+# TYPE shortener_cache_hit_ratio gauge
+shortener_cache_hit_ratio 0.75
+`), "shortener_cache_hit_ratio"))
+}
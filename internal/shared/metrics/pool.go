@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquired = prometheus.NewDesc(namespace+"_pgx_pool_acquired_conns",
+		"Connections currently in use.", nil, nil)
+	poolIdle = prometheus.NewDesc(namespace+"_pgx_pool_idle_conns",
+		"Connections currently idle.", nil, nil)
+	poolTotal = prometheus.NewDesc(namespace+"_pgx_pool_total_conns",
+		"Connections currently open.", nil, nil)
+	poolMax = prometheus.NewDesc(namespace+"_pgx_pool_max_conns",
+		"Largest number of connections the pool may open.", nil, nil)
+	poolAcquires = prometheus.NewDesc(namespace+"_pgx_pool_acquires_total",
+		"Connections acquired from the pool.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_pgx_pool_empty_acquires_total",
+		"Acquires that had to wait for a connection.", nil, nil)
+	poolCanceledAcquires = prometheus.NewDesc(namespace+"_pgx_pool_canceled_acquires_total",
+		"Acquires cancelled before getting a connection.", nil, nil)
+	poolAcquireDuration = prometheus.NewDesc(namespace+"_pgx_pool_acquire_duration_seconds_total",
+		"Time spent acquiring connections.", nil, nil)
+)
+
+// poolCollector reads the pool statistics on every scrape.
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(st.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(st.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(st.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(st.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(st.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(st.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquires, prometheus.CounterValue, float64(st.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, st.AcquireDuration().Seconds())
+}